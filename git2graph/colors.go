@@ -0,0 +1,284 @@
+package git2graph
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+)
+
+// ColorAllocator allocate the colors of the lanes. A layout calls Reset before laying out new nodes.
+type ColorAllocator interface {
+	Reset()
+	Get(tip *OutputNode, nodeIdx int) string // Color of the lane of tip, starting at the node nodeIdx
+	Release(color string, nodeIdx int)       // The lane of color ended at the node nodeIdx
+}
+
+// PaletteAllocator give the first free color of the palette, a released color is free again 2 nodes after the
+// end of its lane. Once all the colors are in use, Generate is called for a new color (#000 when it is nil).
+type PaletteAllocator struct {
+	Palette  []Color
+	Generate func(colors []string) string // New color, distinct from the colors already allocated
+	colors   []Color
+}
+
+// NewPaletteAllocator allocator of the colors of the palette only
+func NewPaletteAllocator(palette []Color) *PaletteAllocator {
+	a := &PaletteAllocator{Palette: palette}
+	a.Reset()
+	return a
+}
+
+// NewGeneratedAllocator allocator of the colors of the palette, followed by generated colors
+func NewGeneratedAllocator(palette []Color) *PaletteAllocator {
+	a := NewPaletteAllocator(palette)
+	a.Generate = GenerateColor
+	return a
+}
+
+// Reset free all the colors, and forget the generated ones
+func (a *PaletteAllocator) Reset() {
+	a.colors = make([]Color, 0, len(a.Palette))
+	for _, color := range a.Palette {
+		a.colors = append(a.colors, color)
+	}
+}
+
+// Get first free color
+func (a *PaletteAllocator) Get(tip *OutputNode, nodeIdx int) string {
+	return a.get(nodeIdx, 0)
+}
+
+// get first free color, starting from the color at index first
+func (a *PaletteAllocator) get(nodeIdx, first int) string {
+	colorToTakeIdx := -1
+	for i := range a.colors {
+		idx := (first + i) % len(a.colors)
+		if color := a.colors[idx]; nodeIdx >= color.ReleaseIdx+2 && !color.InUse {
+			colorToTakeIdx = idx
+			break
+		}
+	}
+	if colorToTakeIdx == -1 {
+		if a.Generate == nil {
+			log.Error("Not enough colors")
+			return "#000"
+		}
+		colors := make([]string, 0, len(a.colors))
+		for _, color := range a.colors {
+			colors = append(colors, color.color)
+		}
+		a.colors = append(a.colors, Color{-2, a.Generate(colors), false})
+		colorToTakeIdx = len(a.colors) - 1
+	}
+	a.colors[colorToTakeIdx].InUse = true
+	return a.colors[colorToTakeIdx].color
+}
+
+// Release free the color. A color can be in the palette more than once, the entry in use is freed.
+func (a *PaletteAllocator) Release(color string, nodeIdx int) {
+	for colorIdx, colorObj := range a.colors {
+		if color == colorObj.color && colorObj.InUse {
+			a.colors[colorIdx].ReleaseIdx = nodeIdx
+			a.colors[colorIdx].InUse = false
+			break
+		}
+	}
+}
+
+// StableAllocator color of a lane derived from the name of the branch at its tip, or from the id of its tip when
+// there is no branch, so a branch keeps its color whatever the history around it. When the color is in use by
+// another lane, the next free color of the palette is used.
+type StableAllocator struct {
+	PaletteAllocator
+}
+
+// NewStableAllocator allocator of the colors of the palette, chosen from the branch names
+func NewStableAllocator(palette []Color) *StableAllocator {
+	a := &StableAllocator{}
+	a.Palette = palette
+	a.Reset()
+	return a
+}
+
+// Get color of the branch of tip, or the next free one.
+// The branch is hashed to a color of the palette, never to a generated color since their number varies.
+func (a *StableAllocator) Get(tip *OutputNode, nodeIdx int) string {
+	if len(a.Palette) == 0 {
+		return a.get(nodeIdx, 0)
+	}
+	h := fnv.New32a()
+	h.Write([]byte(laneName(tip)))
+	return a.get(nodeIdx, int(h.Sum32()%uint32(len(a.Palette))))
+}
+
+// laneName name of the local branch pointing at the tip, else of the remote branch without the remote
+// (origin/master and master have the same color), else the id of the tip
+func laneName(tip *OutputNode) string {
+	refs := NodeRefs(tip.InitialNode)
+	for _, ref := range refs {
+		if ref.Type == LocalBranchRef {
+			return ref.Name
+		}
+	}
+	for _, ref := range refs {
+		if ref.Type == RemoteBranchRef {
+			if i := strings.Index(ref.Name, "/"); i >= 0 {
+				return ref.Name[i+1:]
+			}
+			return ref.Name
+		}
+	}
+	return tip.ID
+}
+
+// NodeRefs refs of a node, read from a repository ([]Ref) or decoded from json
+func NodeRefs(node map[string]interface{}) []Ref {
+	switch refs := node["refs"].(type) {
+	case []Ref:
+		return refs
+	case []interface{}:
+		out := make([]Ref, 0, len(refs))
+		for _, ref := range refs {
+			if m, ok := ref.(map[string]interface{}); ok {
+				name, _ := m["name"].(string)
+				refType, _ := m["type"].(string)
+				out = append(out, Ref{name, RefType(refType)})
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+// LegendEntry color of a value of the attribute used by ColorBy
+type LegendEntry struct {
+	Value string `json:"value"`
+	Color string `json:"color"`
+	Count int    `json:"count"` // Number of nodes with the value
+}
+
+// ColorBy color the nodes laid out (output of Get), and the paths to their parents, by the value of their attribute
+// key (eg: author_email, or any attribute of the input nodes) instead of by lane. The values get the colors of the
+// palette in order of appearance, then generated colors. It returns the legend, in the same order.
+func ColorBy(nodes []map[string]interface{}, key string, palette []Color) []LegendEntry {
+	legend := make([]LegendEntry, 0)
+	byValue := make(map[string]int)
+	colors := make([]string, 0)
+	for _, node := range nodes {
+		value := ""
+		if v, ok := node[key]; ok && v != nil {
+			value = fmt.Sprint(v)
+		}
+		idx, ok := byValue[value]
+		if !ok {
+			color := ""
+			if len(legend) < len(palette) {
+				color = palette[len(legend)].color
+			} else {
+				color = GenerateColor(colors)
+			}
+			colors = append(colors, color)
+			idx = len(legend)
+			byValue[value] = idx
+			legend = append(legend, LegendEntry{Value: value, Color: color})
+		}
+		legend[idx].Count++
+		node["color"] = legend[idx].Color
+		if paths, ok := node["parents_paths"].([]Path); ok {
+			colored := make([]Path, 0, len(paths))
+			for _, path := range paths {
+				colored = append(colored, Path{path.ID, path.Path, legend[idx].Color})
+			}
+			node["parents_paths"] = colored
+		}
+	}
+	return legend
+}
+
+const (
+	goldenAngle      = 137.508 // Hues spaced by the golden angle never repeat and stay evenly spread
+	minColorDistance = 60      // Minimum distance between two generated colors, in rgb space
+)
+
+// GenerateColor color with a hue spaced by the golden angle from the previous ones, and distinct from the colors.
+// It is never one of the colors.
+// The saturation and lightness are close to the ones of DefaultColors, readable on light and dark backgrounds.
+func GenerateColor(colors []string) string {
+	lightnesses := []float64{0.6, 0.5, 0.7}
+	rgbs := make([][3]int, 0, len(colors))
+	for _, color := range colors {
+		if r, g, b, ok := parseHex(color); ok {
+			rgbs = append(rgbs, [3]int{r, g, b})
+		}
+	}
+	var color string
+	// The distance is lowered when there are too many colors to keep it
+	for distance := float64(minColorDistance); distance >= 0; distance -= 10 {
+		for i := 0; i < 360; i++ {
+			n := len(colors) + i
+			color = hsl(math.Mod(float64(n)*goldenAngle, 360), 0.55, lightnesses[n%len(lightnesses)])
+			if distinct(color, rgbs, distance) {
+				return color
+			}
+		}
+	}
+	// Every candidate is allocated, any other color
+	for i := 0; i < 1<<24; i++ {
+		color = fmt.Sprintf("#%06x", i)
+		if distinct(color, rgbs, 0) {
+			return color
+		}
+	}
+	return color
+}
+
+// distinct the color is different from all the colors, and at least at distance of them
+func distinct(color string, rgbs [][3]int, distance float64) bool {
+	r, g, b, _ := parseHex(color)
+	for _, other := range rgbs {
+		dr, dg, db := r-other[0], g-other[1], b-other[2]
+		if d := math.Sqrt(float64(dr*dr + dg*dg + db*db)); d == 0 || d < distance {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHex components of a #rrggbb or #rgb color
+func parseHex(color string) (r, g, b int, ok bool) {
+	if len(color) == 4 && color[0] == '#' {
+		_, err := fmt.Sscanf(color, "#%1x%1x%1x", &r, &g, &b)
+		return r * 17, g * 17, b * 17, err == nil
+	}
+	if len(color) != 7 || color[0] != '#' {
+		return 0, 0, 0, false
+	}
+	_, err := fmt.Sscanf(color, "#%02x%02x%02x", &r, &g, &b)
+	return r, g, b, err == nil
+}
+
+// hsl #rrggbb color of a hue in degrees, saturation and lightness between 0 and 1
+func hsl(h, s, l float64) string {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return fmt.Sprintf("#%02x%02x%02x", int(math.Round((r+m)*255)), int(math.Round((g+m)*255)), int(math.Round((b+m)*255)))
+}
@@ -0,0 +1,171 @@
+package git2graph
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestGeneratedColors(t *testing.T) {
+	var colors = []Color{
+		Color{-2, "#5aa1be", false},
+		Color{-2, "#c065b8", false},
+	}
+	inputNodes := make([]map[string]interface{}, 0)
+	for i := 0; i < 20; i++ {
+		inputNodes = append(inputNodes, map[string]interface{}{"id": fmt.Sprint(i), "parents": []string{"20"}})
+	}
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "20", "parents": []string{}})
+	out, _ := NewLayout(Options{Allocator: NewGeneratedAllocator(colors)}).Get(inputNodes)
+	seen := make(map[string]bool)
+	for _, node := range out[:20] {
+		color := node["color"].(string)
+		if seen[color] || color == "#000" {
+			t.Errorf("Node %s, Expected a new color, Actual: %s", node["id"], color)
+		}
+		seen[color] = true
+	}
+	if out[0]["color"] != "#5aa1be" || out[1]["color"] != "#c065b8" {
+		t.Errorf("Expected the colors of the palette first, Actual: %s %s", out[0]["color"], out[1]["color"])
+	}
+
+	// Generated colors are released like the colors of the palette
+	expected, _ := NewLayout(Options{Colors: DefaultColors}).Get(inputNodes[18:])
+	actual, _ := NewLayout(Options{Allocator: NewGeneratedAllocator(DefaultColors)}).Get(inputNodes[18:])
+	for i := range expected {
+		if expected[i]["color"] != actual[i]["color"] {
+			t.Errorf("Node %d, Expected: %s, Actual: %s", i, expected[i]["color"], actual[i]["color"])
+		}
+	}
+}
+
+func TestGenerateColor(t *testing.T) {
+	colors := make([]string, 0)
+	for i := 0; i < 200; i++ {
+		color := GenerateColor(colors)
+		for _, other := range colors {
+			if color == other {
+				t.Fatalf("Color %d, %s was already generated", i, color)
+			}
+		}
+		colors = append(colors, color)
+	}
+
+	// Every candidate is allocated, the color is still not one of them
+	colors = make([]string, 0)
+	for n := 720; n < 720+360; n++ {
+		colors = append(colors, hsl(math.Mod(float64(n)*goldenAngle, 360), 0.55, []float64{0.6, 0.5, 0.7}[n%3]))
+	}
+	for len(colors) < 720 {
+		colors = append(colors, "none")
+	}
+	color := GenerateColor(colors)
+	for _, other := range colors {
+		if color == other {
+			t.Errorf("Expected a color that is not allocated, Actual: %s", color)
+		}
+	}
+
+	if hsl(0, 1, 0.5) != "#ff0000" || hsl(240, 1, 0.25) != "#000080" {
+		t.Errorf("Expected pure red and navy, Actual: %s %s", hsl(0, 1, 0.5), hsl(240, 1, 0.25))
+	}
+}
+
+func TestReleaseColorInUse(t *testing.T) {
+	// The palette has #111 twice, releasing it frees the entry in use, not the one already free
+	a := NewPaletteAllocator([]Color{{-2, "#111", false}, {-2, "#222", false}, {-2, "#111", false}})
+	a.Get(nil, 0)
+	a.Get(nil, 0)
+	a.Release("#111", 1)
+	if color := a.Get(nil, 2); color != "#111" {
+		t.Fatalf("Expected the second #111, Actual: %s", color)
+	}
+	a.Release("#111", 3)
+	a.Get(nil, 10)
+	if color := a.Get(nil, 10); color != "#111" {
+		t.Errorf("Expected both #111 to be free, Actual: %s", color)
+	}
+}
+
+func TestStableColors(t *testing.T) {
+	refs := func(name string) []interface{} {
+		return []interface{}{map[string]interface{}{"name": name, "type": "branch"}}
+	}
+	inputNodes := make([]map[string]interface{}, 0)
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "1", "parents": []string{"2"}, "refs": refs("main")})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "2", "parents": []string{}})
+	out, _ := NewLayout(Options{Allocator: NewStableAllocator(DefaultColors)}).Get(inputNodes)
+	mainColor := out[0]["color"]
+
+	// Other lane above main
+	inputNodes = append([]map[string]interface{}{{"id": "a", "parents": []string{"2"}, "refs": refs("feature")}}, inputNodes...)
+	out, _ = NewLayout(Options{Allocator: NewStableAllocator(DefaultColors)}).Get(inputNodes)
+	if out[1]["color"] != mainColor {
+		t.Errorf("Expected main to keep its color %s, Actual: %s", mainColor, out[1]["color"])
+	}
+
+	// origin/main has the color of main, a lane can not have the color of another open lane
+	inputNodes[0] = map[string]interface{}{"id": "a", "parents": []string{"2"}, "refs": []interface{}{map[string]interface{}{"name": "origin/main", "type": "remote"}}}
+	out, _ = NewLayout(Options{Allocator: NewStableAllocator(DefaultColors)}).Get(inputNodes)
+	if out[0]["color"] != mainColor || out[1]["color"] == mainColor {
+		t.Errorf("Expected origin/main to have the color %s and main another one, Actual: %s %s", mainColor, out[0]["color"], out[1]["color"])
+	}
+}
+
+func TestStableColorsGenerated(t *testing.T) {
+	palette := []Color{{-2, "#5aa1be", false}, {-2, "#c065b8", false}}
+	lane := func(id, branch string) *OutputNode {
+		refs := []Ref{{branch, LocalBranchRef}}
+		return &OutputNode{ID: id, InitialNode: map[string]interface{}{"refs": refs}}
+	}
+	a := NewStableAllocator(palette)
+	a.Generate = GenerateColor
+	for _, branch := range []string{"main", "develop", "feature", "release"} {
+		a.Reset()
+		expected := a.Get(lane("1", branch), 0)
+
+		// Generated colors once the palette is in use do not change the color of the branch
+		a.Reset()
+		colors := make([]string, 0)
+		for _, other := range []string{"a", "b", "c"} {
+			colors = append(colors, a.Get(lane(other, other), 0))
+		}
+		for _, color := range colors {
+			a.Release(color, 0)
+		}
+		if actual := a.Get(lane("1", branch), 10); actual != expected {
+			t.Errorf("Branch: %s, Expected: %s, Actual: %s", branch, expected, actual)
+		}
+	}
+}
+
+func TestColorBy(t *testing.T) {
+	inputNodes := make([]map[string]interface{}, 0)
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "1", "parents": []string{"3", "2"}, "team": "core"})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "2", "parents": []string{"3"}, "team": "ui"})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "3", "parents": []string{}, "team": "core"})
+	out, _ := Get(inputNodes)
+	out = append(out, map[string]interface{}{"id": "4", "parents": []string{}, "parents_paths": []Path{}})
+	legend := ColorBy(out, "team", customColors)
+	expected := []LegendEntry{{"core", "color1", 2}, {"ui", "color2", 1}, {"", "color3", 1}}
+	if !reflect.DeepEqual(legend, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, legend)
+	}
+	for i, color := range []string{"color1", "color2", "color1", "color3"} {
+		if out[i]["color"] != color {
+			t.Errorf("Node %d, Expected: %s, Actual: %s", i, color, out[i]["color"])
+		}
+		for _, path := range out[i]["parents_paths"].([]Path) {
+			if path.Color != color {
+				t.Errorf("Node %d, Expected paths of color %s, Actual: %s", i, color, path.Color)
+			}
+		}
+	}
+
+	// More values than colors in the palette
+	legend = ColorBy(out, "id", customColors[:2])
+	if len(legend) != 4 || legend[2].Color == legend[3].Color || legend[2].Color == "#000" {
+		t.Errorf("Expected generated colors, Actual: %v", legend)
+	}
+}
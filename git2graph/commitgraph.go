@@ -0,0 +1,215 @@
+package git2graph
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	graphParentNone     = 0x70000000
+	graphExtraEdgesFlag = 0x80000000
+	graphLastEdgeFlag   = 0x80000000
+)
+
+// commitGraph commit-graph file, or chain of split commit-graph files (base first)
+type commitGraph struct {
+	layers []*commitGraphLayer
+}
+
+type commitGraphLayer struct {
+	fanout [256]uint32
+	oids   []byte // OIDL chunk, 20 bytes per commit
+	data   []byte // CDAT chunk, 36 bytes per commit
+	edges  []byte // EDGE chunk, parents of octopus merges
+	offset int    // Number of commits in the layers below
+}
+
+// commitGraphEntry topology of a commit read from the commit-graph
+type commitGraphEntry struct {
+	tree       string
+	parents    []string
+	generation uint32
+	commitTime int64
+}
+
+// openCommitGraph read objects/info/commit-graph, or the objects/info/commit-graphs chain.
+// It returns nil when the repository has no commit-graph.
+func openCommitGraph(objectsDir string) (*commitGraph, error) {
+	infoDir := filepath.Join(objectsDir, "info")
+	if _, err := os.Stat(filepath.Join(infoDir, "commit-graph")); err == nil {
+		layer, err := readCommitGraphLayer(filepath.Join(infoDir, "commit-graph"))
+		if err != nil {
+			return nil, err
+		}
+		return &commitGraph{layers: []*commitGraphLayer{layer}}, nil
+	}
+	f, err := os.Open(filepath.Join(infoDir, "commit-graphs", "commit-graph-chain"))
+	if err != nil {
+		return nil, nil
+	}
+	defer f.Close()
+	g := &commitGraph{}
+	offset := 0
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		hash := strings.TrimSpace(scanner.Text())
+		if hash == "" {
+			continue
+		}
+		layer, err := readCommitGraphLayer(filepath.Join(infoDir, "commit-graphs", "graph-"+hash+".graph"))
+		if err != nil {
+			return nil, err
+		}
+		layer.offset = offset
+		offset += layer.nbCommits()
+		g.layers = append(g.layers, layer)
+	}
+	return g, scanner.Err()
+}
+
+func readCommitGraphLayer(path string) (*commitGraphLayer, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// Header: signature, version, hash version, number of chunks, number of base graphs
+	if len(content) < 8 || string(content[:4]) != "CGPH" || content[4] != 1 || content[5] != 1 {
+		return nil, fmt.Errorf("%s: unsupported commit-graph", path)
+	}
+	nbChunks := int(content[6])
+	if len(content) < 8+(nbChunks+1)*12 {
+		return nil, fmt.Errorf("%s: truncated commit-graph", path)
+	}
+	chunks := make(map[string][]byte)
+	for i := 0; i < nbChunks; i++ {
+		entry := content[8+i*12:]
+		start := binary.BigEndian.Uint64(entry[4:12])
+		end := binary.BigEndian.Uint64(entry[16:24])
+		if start > end || end > uint64(len(content)) {
+			return nil, fmt.Errorf("%s: bad chunk offset", path)
+		}
+		chunks[string(entry[:4])] = content[start:end]
+	}
+	layer := &commitGraphLayer{oids: chunks["OIDL"], data: chunks["CDAT"], edges: chunks["EDGE"]}
+	fanout := chunks["OIDF"]
+	if len(fanout) != 256*4 {
+		return nil, fmt.Errorf("%s: missing fanout", path)
+	}
+	for i := 0; i < 256; i++ {
+		layer.fanout[i] = binary.BigEndian.Uint32(fanout[i*4:])
+		if i > 0 && layer.fanout[i] < layer.fanout[i-1] {
+			return nil, fmt.Errorf("%s: malformed fanout", path)
+		}
+	}
+	nb := layer.nbCommits()
+	if len(layer.oids) != nb*20 || len(layer.data) != nb*36 {
+		return nil, fmt.Errorf("%s: malformed chunks", path)
+	}
+	return layer, nil
+}
+
+func (l *commitGraphLayer) nbCommits() int {
+	return int(l.fanout[255])
+}
+
+// lookup get the position of a commit in the whole chain
+func (g *commitGraph) lookup(sha string) (int, bool) {
+	rawSha, err := hex.DecodeString(sha)
+	if err != nil || len(rawSha) != 20 {
+		return 0, false
+	}
+	for _, l := range g.layers {
+		lo := 0
+		if rawSha[0] > 0 {
+			lo = int(l.fanout[rawSha[0]-1])
+		}
+		hi := int(l.fanout[rawSha[0]])
+		i := lo + sort.Search(hi-lo, func(i int) bool {
+			return bytes.Compare(l.oids[(lo+i)*20:(lo+i)*20+20], rawSha) >= 0
+		})
+		if i < hi && bytes.Equal(l.oids[i*20:i*20+20], rawSha) {
+			return l.offset + i, true
+		}
+	}
+	return 0, false
+}
+
+// layer get the layer of the commit at pos and its position in the layer, nil when pos is out of the chain
+func (g *commitGraph) layer(pos int) (*commitGraphLayer, int) {
+	if pos < 0 {
+		return nil, 0
+	}
+	for _, l := range g.layers {
+		if pos < l.offset+l.nbCommits() {
+			return l, pos - l.offset
+		}
+	}
+	return nil, 0
+}
+
+func (g *commitGraph) sha(pos int) (string, error) {
+	l, i := g.layer(pos)
+	if l == nil {
+		return "", fmt.Errorf("commit-graph: bad position %d", pos)
+	}
+	return hex.EncodeToString(l.oids[i*20 : i*20+20]), nil
+}
+
+// entry read the topology of the commit at pos
+func (g *commitGraph) entry(pos int) (commitGraphEntry, error) {
+	l, i := g.layer(pos)
+	if l == nil {
+		return commitGraphEntry{}, fmt.Errorf("commit-graph: bad position %d", pos)
+	}
+	data := l.data[i*36 : i*36+36]
+	entry := commitGraphEntry{}
+	entry.tree = hex.EncodeToString(data[:20])
+	entry.parents = make([]string, 0)
+	addParent := func(pos uint32) error {
+		sha, err := g.sha(int(pos))
+		if err != nil {
+			return err
+		}
+		entry.parents = append(entry.parents, sha)
+		return nil
+	}
+	parent1 := binary.BigEndian.Uint32(data[20:24])
+	parent2 := binary.BigEndian.Uint32(data[24:28])
+	if parent1 != graphParentNone {
+		if err := addParent(parent1); err != nil {
+			return commitGraphEntry{}, err
+		}
+	}
+	if parent2&graphExtraEdgesFlag != 0 {
+		// Octopus merge, parents 2+ are listed in the EDGE chunk
+		for edgeIdx := int(parent2 &^ graphExtraEdgesFlag); ; edgeIdx++ {
+			if (edgeIdx+1)*4 > len(l.edges) {
+				return commitGraphEntry{}, fmt.Errorf("commit-graph: bad edge %d", edgeIdx)
+			}
+			edge := binary.BigEndian.Uint32(l.edges[edgeIdx*4:])
+			if err := addParent(edge &^ graphLastEdgeFlag); err != nil {
+				return commitGraphEntry{}, err
+			}
+			if edge&graphLastEdgeFlag != 0 {
+				break
+			}
+		}
+	} else if parent2 != graphParentNone {
+		if err := addParent(parent2); err != nil {
+			return commitGraphEntry{}, err
+		}
+	}
+	// Generation is the 30 upper bits, commit time the 34 lower bits
+	genAndTime := binary.BigEndian.Uint64(data[28:36])
+	entry.generation = uint32(genAndTime >> 34)
+	entry.commitTime = int64(genAndTime & (1<<34 - 1))
+	return entry, nil
+}
@@ -0,0 +1,162 @@
+package git2graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config defaults of the command line, read from a config file
+type Config struct {
+	Palette     []string `json:"palette" yaml:"palette"`           // Colors of the lanes, replace DefaultColors
+	Colors      string   `json:"colors" yaml:"colors"`             // Color strategy: palette, generate or stable
+	ColorBy     string   `json:"color_by" yaml:"color_by"`         // Attribute the nodes are colored by
+	Format      string   `json:"format" yaml:"format"`             // Output format
+	ColumnWidth int      `json:"column_width" yaml:"column_width"` // Distance between two lanes in pixels
+	RowHeight   int      `json:"row_height" yaml:"row_height"`     // Distance between two rows in pixels
+	Radius      int      `json:"radius" yaml:"radius"`             // Radius of the nodes in pixels
+	Revisions   []string `json:"revisions" yaml:"revisions"`       // Revision specs read from a repository
+}
+
+// configNames names of the config file of a repository
+var configNames = []string{".git2graph.yml", ".git2graph.yaml", ".git2graph.json"}
+
+// userConfigDir directory of the config of the user, replaced by the tests
+var userConfigDir = os.UserConfigDir
+
+var hexColorRegexp = regexp.MustCompile("^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$")
+
+// ReadConfig read a config file, json when its extension is .json, yaml otherwise.
+// Unknown options and palette colors that are not #rgb or #rrggbb are errors.
+func ReadConfig(path string) (Config, error) {
+	var cfg Config
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if strings.HasSuffix(path, ".json") {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&cfg)
+	} else {
+		err = yaml.UnmarshalStrict(data, &cfg)
+	}
+	if err == nil {
+		for _, color := range cfg.Palette {
+			if !hexColorRegexp.MatchString(color) {
+				err = fmt.Errorf("palette: %q is not a #rgb or #rrggbb color", color)
+				break
+			}
+		}
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// LoadConfig read the config of the user (git2graph/config.yml in the user config directory), then the config of
+// the repository containing dir (.git2graph.yml at the root of the repository, or in any directory up to it).
+// The options of the repository override the ones of the user.
+func LoadConfig(dir string) (Config, error) {
+	var cfg Config
+	paths := make([]string, 0)
+	if userDir, err := userConfigDir(); err == nil {
+		if path := findConfig(filepath.Join(userDir, "git2graph"), "config.yml", "config.yaml", "config.json"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if path := findRepoConfig(dir); path != "" {
+		paths = append(paths, path)
+	}
+	for _, path := range paths {
+		other, err := ReadConfig(path)
+		if err != nil {
+			return cfg, err
+		}
+		cfg.merge(other)
+	}
+	return cfg, nil
+}
+
+// findConfig first of the names that is a file of dir
+func findConfig(dir string, names ...string) string {
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// findRepoConfig config file of dir or of its parents, up to the root of the repository.
+// Outside of a repository, no config file is read.
+func findRepoConfig(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	dirs := make([]string, 0)
+	for {
+		dirs = append(dirs, dir)
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+	for _, dir := range dirs {
+		if path := findConfig(dir, configNames...); path != "" {
+			return path
+		}
+	}
+	return ""
+}
+
+// merge override the options with the ones set in other
+func (c *Config) merge(other Config) {
+	if len(other.Palette) > 0 {
+		c.Palette = other.Palette
+	}
+	if other.Colors != "" {
+		c.Colors = other.Colors
+	}
+	if other.ColorBy != "" {
+		c.ColorBy = other.ColorBy
+	}
+	if other.Format != "" {
+		c.Format = other.Format
+	}
+	if other.ColumnWidth != 0 {
+		c.ColumnWidth = other.ColumnWidth
+	}
+	if other.RowHeight != 0 {
+		c.RowHeight = other.RowHeight
+	}
+	if other.Radius != 0 {
+		c.Radius = other.Radius
+	}
+	if len(other.Revisions) > 0 {
+		c.Revisions = other.Revisions
+	}
+}
+
+// NewPalette colors usable by a layout (eg: "#5aa1be")
+func NewPalette(colors []string) []Color {
+	palette := make([]Color, 0, len(colors))
+	for _, color := range colors {
+		palette = append(palette, Color{-2, color, false})
+	}
+	return palette
+}
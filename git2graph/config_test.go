@@ -0,0 +1,87 @@
+package git2graph
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, "config.yml"), "palette: ['#ff0000', '#00ff00']\ncolors: stable\nrow_height: 30\nrevisions: [main..feature]\n")
+	writeConfig(t, filepath.Join(dir, "config.json"), `{"palette": ["#ff0000", "#00ff00"], "colors": "stable", "row_height": 30, "revisions": ["main..feature"]}`)
+	expected := Config{Palette: []string{"#ff0000", "#00ff00"}, Colors: "stable", RowHeight: 30, Revisions: []string{"main..feature"}}
+	for _, name := range []string{"config.yml", "config.json"} {
+		cfg, err := ReadConfig(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(cfg, expected) {
+			t.Errorf("%s, Expected: %v, Actual: %v", name, expected, cfg)
+		}
+	}
+
+	invalid := map[string]string{
+		"typo.yml":     "row_heigth: 30\n",
+		"typo.json":    `{"row_heigth": 30}`,
+		"palette.yml":  "palette: ['#ff0000', 'ff0000']\n",
+		"palette.json": `{"palette": ["#ff00"]}`,
+	}
+	for name, content := range invalid {
+		writeConfig(t, filepath.Join(dir, name), content)
+		if _, err := ReadConfig(filepath.Join(dir, name)); err == nil {
+			t.Errorf("%s, Expected an error", name)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	userDir := t.TempDir()
+	defer func(dir func() (string, error)) { userConfigDir = dir }(userConfigDir)
+	userConfigDir = func() (string, error) { return userDir, nil }
+	writeConfig(t, filepath.Join(userDir, "git2graph", "config.yml"), "format: svg\nradius: 6\n")
+
+	repo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	subDir := filepath.Join(repo, "sub", "dir")
+	cfg, err := LoadConfig(subDir)
+	if err != nil || !reflect.DeepEqual(cfg, Config{Format: "svg", Radius: 6}) {
+		t.Errorf("Expected the user config, Actual: %v, %v", cfg, err)
+	}
+
+	// The config of the repository overrides the one of the user
+	writeConfig(t, filepath.Join(repo, ".git2graph.yml"), "format: text\n")
+	cfg, err = LoadConfig(subDir)
+	if err != nil || !reflect.DeepEqual(cfg, Config{Format: "text", Radius: 6}) {
+		t.Errorf("Expected the merged config, Actual: %v, %v", cfg, err)
+	}
+
+	// Outside of a repository, the config files of the parent directories are not read
+	outside := t.TempDir()
+	writeConfig(t, filepath.Join(outside, ".git2graph.yml"), "format: text\n")
+	cfg, err = LoadConfig(filepath.Join(outside, "sub"))
+	if err != nil || !reflect.DeepEqual(cfg, Config{Format: "svg", Radius: 6}) {
+		t.Errorf("Expected only the user config, Actual: %v, %v", cfg, err)
+	}
+}
+
+func TestNewPalette(t *testing.T) {
+	layout := NewLayout(Options{Colors: NewPalette([]string{"#ff0000"})})
+	out, _ := layout.Get([]map[string]interface{}{{"id": "1", "parents": []string{}}})
+	if out[0]["color"] != "#ff0000" {
+		t.Errorf("Expected: #ff0000, Actual: %s", out[0]["color"])
+	}
+}
@@ -2,26 +2,68 @@ package git2graph
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"os"
-	"os/exec"
-	"strconv"
-	"strings"
 
 	log "github.com/Sirupsen/logrus"
 )
 
-var colors []Color
-
 // DebugMode Debug mode
 var DebugMode = false
 
 // NoOutput No output
 var NoOutput = false
 
-var index = make(map[string]*OutputNode)
+// Options options used to create a Layout
+type Options struct {
+	Colors    []Color
+	Allocator ColorAllocator // Colors of the lanes, a PaletteAllocator of Colors by default
+	Debug     bool
+}
+
+// Layout owns the state of a graph layout (node index, color allocator, debug mode).
+// Different layouts can be used concurrently, a single layout must not.
+type Layout struct {
+	allocator ColorAllocator
+	index     map[string]*OutputNode
+	debug     bool
+
+	// State kept between two calls to Append
+	nodes                               []*OutputNode
+	processed                           int                   // Number of nodes laid out
+	nextColumn                          int                   // First free column
+	followingNodesWithChildrenBeforeIdx stringSet             // Open lanes, parents of the nodes laid out
+	finalAt                             map[int][]*OutputNode // Nodes that are final once the node at idx is laid out
+	pendingChildren                     map[string][]string   // Children of the nodes not received yet
+	streamed                            bool                  // StreamTree dropped the rows once final
+}
+
+// NewLayout create a new layout engine
+func NewLayout(opts Options) *Layout {
+	l := &Layout{}
+	l.allocator = opts.Allocator
+	if l.allocator == nil {
+		l.allocator = NewPaletteAllocator(opts.Colors)
+	}
+	l.debug = opts.Debug
+	l.reset()
+	return l
+}
+
+func (l *Layout) reset() {
+	l.allocator.Reset()
+	l.index = make(map[string]*OutputNode)
+	l.nodes = nil
+	l.processed = 0
+	l.nextColumn = 0
+	l.followingNodesWithChildrenBeforeIdx = newStringSet()
+	l.finalAt = make(map[int][]*OutputNode)
+	l.pendingChildren = make(map[string][]string)
+	l.streamed = false
+}
 
 // Color color structure
 type Color struct {
@@ -51,30 +93,12 @@ var DefaultColors = []Color{
 	{-2, "#adfb82", false},
 }
 
-func getColor(nodeIdx int) string {
-	colorToTakeIdx := -1
-	for idx, color := range colors {
-		if nodeIdx >= color.ReleaseIdx+2 && !color.InUse {
-			colorToTakeIdx = idx
-			break
-		}
-	}
-	if colorToTakeIdx == -1 {
-		log.Error("Not enough colors")
-		return "#000"
-	}
-	colors[colorToTakeIdx].InUse = true
-	return colors[colorToTakeIdx].color
+func (l *Layout) getColor(tip *OutputNode, nodeIdx int) string {
+	return l.allocator.Get(tip, nodeIdx)
 }
 
-func releaseColor(color string, idx int) {
-	for colorIdx, colorObj := range colors {
-		if color == colorObj.color {
-			colors[colorIdx].ReleaseIdx = idx
-			colors[colorIdx].InUse = false
-			break
-		}
-	}
+func (l *Layout) releaseColor(color string, idx int) {
+	l.allocator.Release(color, idx)
 }
 
 // Types
@@ -99,6 +123,13 @@ type Path struct {
 	Color string  `json:"color"`
 }
 
+// InputNode typed input node
+type InputNode struct {
+	ID      string                 `json:"id"`
+	Parents []string               `json:"parents"`
+	Attrs   map[string]interface{} `json:"attrs,omitempty"` // Non related attributes, kept as the output InitialNode
+}
+
 // OutputNode TODO
 type OutputNode struct {
 	ID                string                 `json:"id"`
@@ -113,10 +144,11 @@ type OutputNode struct {
 	children          []string
 	firstInRow        bool
 	subBranch         map[string]bool
+	layout            *Layout
 }
 
 func (node *OutputNode) addDebug(msg string) {
-	if DebugMode {
+	if node.layout.debug {
 		node.Debug = append(node.Debug, msg)
 	}
 }
@@ -148,7 +180,7 @@ func (node *OutputNode) columnDefined() bool {
 func (node *OutputNode) hasBiggerParentDefined() bool {
 	found := false
 	for _, parentNodeID := range node.Parents {
-		parentNode := index[parentNodeID]
+		parentNode := node.layout.index[parentNodeID]
 		if parentNode.Column > node.Column {
 			found = true
 			break
@@ -160,7 +192,7 @@ func (node *OutputNode) hasBiggerParentDefined() bool {
 func (node *OutputNode) hasOlderParent(idx int) bool {
 	found := false
 	for _, parentNodeID := range node.Parents {
-		parentNode := index[parentNodeID]
+		parentNode := node.layout.index[parentNodeID]
 		if parentNode.Idx > idx {
 			found = true
 			break
@@ -190,7 +222,7 @@ func (node *OutputNode) isPathSubBranch(parentID string) bool {
 func (node *OutputNode) getPathPoint(parentID string, idx int) Point {
 	if idx < 0 {
 		if len(node.parentsPaths[parentID].Path)+idx < 0 {
-			if index[parentID].Idx < index[node.ID].Idx {
+			if node.layout.index[parentID].Idx < node.Idx {
 				log.WithFields(log.Fields{
 					"idx":       idx,
 					"node id":   node.ID,
@@ -230,14 +262,23 @@ func (node *OutputNode) pathLength(parentID string) int {
 	return len(node.parentsPaths[parentID].Path)
 }
 
-// SerializeOutput Json encode object
-func SerializeOutput(out []map[string]interface{}) {
-	if !NoOutput {
-		enc := json.NewEncoder(os.Stdout)
-		if err := enc.Encode(out); err != nil {
-			log.Error("Could not encode json")
-		}
+// SerializeOutput Json encode object to w
+func SerializeOutput(w io.Writer, out []map[string]interface{}) error {
+	if NoOutput {
+		return nil
 	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// InputError error returned when an input node is malformed
+type InputError struct {
+	Idx    int    // Index of the node in the input
+	Field  string // Property of the node that is malformed
+	Reason string
+}
+
+func (e *InputError) Error() string {
+	return fmt.Sprintf("malformed input, node %d: %s %s", e.Idx, e.Field, e.Reason)
 }
 
 // GetInputNodesFromJSON Get nodes from json object
@@ -247,65 +288,171 @@ func GetInputNodesFromJSON(inputJSON []byte) (nodes []map[string]interface{}, er
 	if err != nil {
 		return
 	}
-	for _, node := range nodes {
-		parents := make([]string, 0)
-		nodeParents, ok := node["parents"]
-		if !ok {
-			log.Fatal("malformed json input, node missing parents property")
-		}
-		for _, parent := range nodeParents.([]interface{}) {
-			parents = append(parents, parent.(string))
+	for idx, node := range nodes {
+		if err = decodeParents(idx, node); err != nil {
+			return nil, err
 		}
-		node["parents"] = parents
 	}
 	return
 }
 
-func initNodes(inputNodes []map[string]interface{}) []*OutputNode {
+// GetInputNodesFromNDJSON Get nodes from newline-delimited json, one node per line
+func GetInputNodesFromNDJSON(r io.Reader) (nodes []map[string]interface{}, err error) {
+	err = ReadNDJSON(r, 1000, func(batch []map[string]interface{}) error {
+		nodes = append(nodes, batch...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// ReadNDJSON read newline-delimited json, one node per line, and call fn with batches of at most size nodes,
+// so the whole input is never in memory (eg: with Layout.AppendStream)
+func ReadNDJSON(r io.Reader, size int, fn func([]map[string]interface{}) error) error {
+	dec := json.NewDecoder(r)
+	batch := make([]map[string]interface{}, 0, size)
+	for idx := 0; ; idx++ {
+		var node map[string]interface{}
+		if err := dec.Decode(&node); err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		if node == nil {
+			return &InputError{idx, "node", "must be an object"}
+		}
+		if err := decodeParents(idx, node); err != nil {
+			return err
+		}
+		batch = append(batch, node)
+		if len(batch) >= size {
+			if err := fn(batch); err != nil {
+				return err
+			}
+			batch = make([]map[string]interface{}, 0, size)
+		}
+	}
+	if len(batch) > 0 {
+		return fn(batch)
+	}
+	return nil
+}
+
+// decodeParents convert the decoded parents of a node to a []string
+func decodeParents(idx int, node map[string]interface{}) error {
+	parents := make([]string, 0)
+	nodeParents, ok := node["parents"]
+	if !ok {
+		return &InputError{idx, "parents", "property is missing"}
+	}
+	nodeParentsArr, ok := nodeParents.([]interface{})
+	if !ok {
+		return &InputError{idx, "parents", "property must be an array of string"}
+	}
+	for _, parent := range nodeParentsArr {
+		parentID, ok := parent.(string)
+		if !ok {
+			return &InputError{idx, "parents", "property must be an array of string"}
+		}
+		parents = append(parents, parentID)
+	}
+	node["parents"] = parents
+	return nil
+}
+
+func (l *Layout) initNodes(inputNodes []map[string]interface{}) ([]*OutputNode, error) {
 	out := make([]*OutputNode, 0)
-	for idx, node := range inputNodes {
+	ids := make(map[string]int)
+	for i, node := range inputNodes {
+		idx := len(l.nodes) + i
 		id, ok := node["id"].(string)
 		if !ok {
-			log.Fatal("id property must be a string")
+			return nil, &InputError{idx, "id", "property must be a string"}
 		}
 		parents, ok := node["parents"].([]string)
 		if !ok {
-			log.Fatal("parents property must be an array of string")
-		}
-		newNode := OutputNode{}
-		newNode.InitialNode = node
-		newNode.ID = id
-		newNode.Parents = parents
-		newNode.Column = -1
-		newNode.parentsPaths = make(map[string]Path)
-		newNode.FinalParentsPaths = make([]Path, 0)
-		newNode.Idx = idx
-		newNode.children = make([]string, 0)
-		newNode.Debug = make([]string, 0)
-		newNode.subBranch = make(map[string]bool)
-		out = append(out, &newNode)
+			return nil, &InputError{idx, "parents", "property must be an array of string"}
+		}
+		if err := l.checkNode(idx, id, parents, ids); err != nil {
+			return nil, err
+		}
+		out = append(out, l.newNode(idx, id, parents, node))
 	}
-	return out
+	return out, nil
 }
 
-func initIndex(nodes []*OutputNode) map[string]*OutputNode {
-	index := make(map[string]*OutputNode)
+func (l *Layout) initTypedNodes(inputNodes []InputNode) ([]*OutputNode, error) {
+	out := make([]*OutputNode, 0)
+	ids := make(map[string]int)
+	for i, node := range inputNodes {
+		idx := len(l.nodes) + i
+		if err := l.checkNode(idx, node.ID, node.Parents, ids); err != nil {
+			return nil, err
+		}
+		parents := make([]string, len(node.Parents))
+		copy(parents, node.Parents)
+		out = append(out, l.newNode(idx, node.ID, parents, node.Attrs))
+	}
+	return out, nil
+}
+
+// checkNode reject the nodes the layout can not handle, ids are the ids of the nodes of the same call
+func (l *Layout) checkNode(idx int, id string, parents []string, ids map[string]int) error {
+	if previous, ok := l.index[id]; ok {
+		return &InputError{idx, "id", fmt.Sprintf("is a duplicate of node %d", previous.Idx)}
+	}
+	if previous, ok := ids[id]; ok {
+		return &InputError{idx, "id", fmt.Sprintf("is a duplicate of node %d", previous)}
+	}
+	ids[id] = idx
+	for _, parentID := range parents {
+		if parentID == id {
+			return &InputError{idx, "parents", "contains the node itself"}
+		}
+	}
+	return nil
+}
+
+func (l *Layout) newNode(idx int, id string, parents []string, initialNode map[string]interface{}) *OutputNode {
+	newNode := OutputNode{}
+	newNode.InitialNode = initialNode
+	newNode.ID = id
+	newNode.Parents = parents
+	newNode.Column = -1
+	newNode.parentsPaths = make(map[string]Path)
+	newNode.FinalParentsPaths = make([]Path, 0)
+	newNode.Idx = idx
+	newNode.children = make([]string, 0)
+	newNode.Debug = make([]string, 0)
+	newNode.subBranch = make(map[string]bool)
+	newNode.layout = l
+	return &newNode
+}
+
+// add index the nodes and link them to their children
+func (l *Layout) add(nodes []*OutputNode) {
 	for _, node := range nodes {
 		// Remove bad parents (parents that are before children)
 		for idx := len(node.Parents) - 1; idx >= 0; idx-- {
-			if index[node.Parents[idx]] != nil {
+			if l.index[node.Parents[idx]] != nil {
 				node.Parents = append(node.Parents[:idx], node.Parents[idx+1:]...)
 			}
 		}
-		index[node.ID] = node
-	}
-	return index
-}
-
-func initChildren(nodes []*OutputNode) {
-	for _, node := range nodes {
+		l.index[node.ID] = node
+		l.nodes = append(l.nodes, node)
+		// Children are always before their parents
+		if children, ok := l.pendingChildren[node.ID]; ok {
+			node.children = children
+			delete(l.pendingChildren, node.ID)
+		}
 		for _, parentID := range node.Parents {
-			index[parentID].children = append(index[parentID].children, node.ID)
+			if parent := l.index[parentID]; parent != nil {
+				parent.children = append(parent.children, node.ID)
+			} else {
+				l.pendingChildren[parentID] = append(l.pendingChildren[parentID], node.ID)
+			}
 		}
 	}
 }
@@ -328,340 +475,723 @@ func (s *stringSet) Remove(in string) {
 	delete(s.Items, in)
 }
 
-func setColumns(nodes []*OutputNode) {
-	followingNodesWithChildrenBeforeIdx := newStringSet()
-	nextColumn := 0
-	for _, node := range nodes {
-		// Set column if not defined
-		if !node.columnDefined() {
-			node.Column = nextColumn
-			node.addDebug(fmt.Sprintf("Column set to %d", nextColumn))
-			node.Color = getColor(node.Idx)
-			nextColumn++
-			log.WithFields(log.Fields{
-				"nextColumn": nextColumn,
-				"operator":   "++",
-				"created":    node.ID,
-			}).Debug("new node ++")
+// process lay out the nodes in order, up to the first node with a parent that is not received yet.
+// final is called with every node as soon as it is final.
+func (l *Layout) process(final func(*OutputNode) error) error {
+	for ; l.processed < len(l.nodes); l.processed++ {
+		node := l.nodes[l.processed]
+		// A node is final once its last parent is laid out, nothing changes its column and paths anymore
+		last := node.Idx
+		for _, parentID := range node.Parents {
+			parent := l.index[parentID]
+			if parent == nil {
+				return nil
+			}
+			if parent.Idx > last {
+				last = parent.Idx
+			}
 		}
+		l.finalAt[last] = append(l.finalAt[last], node)
 
-		// Cache the following node with child before the current node
-		for _, parentID := range node.Parents {
-			followingNodesWithChildrenBeforeIdx.Add(parentID)
-		}
-		followingNodesWithChildrenBeforeIdx.Remove(node.ID)
-
-		// Each children that are merging
-		processedNodes := make(map[string]map[string]bool)
-		for _, childID := range node.children {
-			child := index[childID]
-			if node.Column < child.getPathPoint(node.ID, -2).X {
-				if !child.isPathSubBranch(node.ID) &&
-					!(child.hasOlderParent(node.Idx) && child.getPathPoint(node.ID, 1).Type == MERGE_TO) {
-					nextColumn--
-					log.WithFields(log.Fields{
-						"nextColumn": nextColumn,
-						"operator":   "--",
-						"merging":    child.ID,
-						"into":       node.ID,
-						"sub":        child.isPathSubBranch(node.ID),
-					}).Debug("node merging --")
-					releaseColor(child.getPathColor(node.ID), node.Idx)
-				}
+		l.setColumn(node)
 
-				if !child.firstInRow && !child.isPathSubBranch(node.ID) && !child.hasOlderParent(node.Idx) {
-					child.setPathColor(node.ID, child.Color)
+		for _, finalNode := range l.finalAt[node.Idx] {
+			finalNode.finalize()
+			if final != nil {
+				if err := final(finalNode); err != nil {
+					l.processed++
+					return err
 				}
-				releaseColor(child.getPathColor(node.ID), node.Idx)
-
-				// Insert before the last element
-				pos := child.pathLength(node.ID) - 1
-				point := Point{child.getPathPoint(node.ID, -2).X, node.Idx, MERGE_BACK}
-				child.insert(node.ID, pos, point)
-
-				// Nodes that are following the current node
-				for followingNodeID := range followingNodesWithChildrenBeforeIdx.Items {
-					followingNode := index[followingNodeID]
-					if followingNode.Idx > node.Idx {
-						// Following nodes that have a child before the current node
-						for _, followingNodeChildID := range followingNode.children {
-							followingNodeChild := index[followingNodeChildID]
-							if followingNodeChild.Idx < node.Idx {
-								// Following node child has a path that is higher than the current path being merged
-								if followingNodeChild.GetPathHeightAtIdx(followingNode.ID, node.Idx) > child.getPathPoint(node.ID, -2).X {
-
-									// Index to delete is the one before last
-									idxRemove := followingNodeChild.pathLength(followingNode.ID) - 1
-									if idxRemove < 0 {
-										continue
-									}
-									// Remove second before last node has same Y, remove the before last node
-									for followingNodeChild.pathLength(followingNode.ID) > idxRemove &&
-										followingNodeChild.getPathPoint(followingNode.ID, idxRemove).Y == followingNodeChild.getPathPoint(followingNode.ID, idxRemove-1).Y {
-										followingNodeChild.remove(followingNode.ID, idxRemove-1)
-										idxRemove--
-									}
+			}
+		}
+		delete(l.finalAt, node.Idx)
+	}
+	return nil
+}
 
-									// Calculate nb of merging nodes
-									nbNodesMergingBack := 0
-									for _, childID := range node.children {
-										child := index[childID]
-										if node.Column < child.getPathPoint(node.ID, -2).X &&
-											child.getPathPoint(node.ID, -2).X < followingNodeChild.GetPathHeightAtIdx(followingNode.ID, node.Idx) &&
-											!child.isPathSubBranch(node.ID) &&
-											!(child.hasOlderParent(node.Idx) && child.getPathPoint(node.ID, 1).Type == MERGE_TO) {
-											nbNodesMergingBack++
-										}
-									}
+func (l *Layout) setColumn(node *OutputNode) {
+	// Set column if not defined
+	if !node.columnDefined() {
+		node.Column = l.nextColumn
+		node.addDebug(fmt.Sprintf("Column set to %d", l.nextColumn))
+		node.Color = l.getColor(node, node.Idx)
+		l.nextColumn++
+		log.WithFields(log.Fields{
+			"nextColumn": l.nextColumn,
+			"operator":   "++",
+			"created":    node.ID,
+		}).Debug("new node ++")
+	}
 
-									if processedNodes[followingNode.ID] != nil && processedNodes[followingNode.ID][followingNodeChild.ID] {
-										continue
+	// Cache the following node with child before the current node
+	for _, parentID := range node.Parents {
+		l.followingNodesWithChildrenBeforeIdx.Add(parentID)
+	}
+	l.followingNodesWithChildrenBeforeIdx.Remove(node.ID)
+
+	// Each children that are merging
+	processedNodes := make(map[string]map[string]bool)
+	for _, childID := range node.children {
+		child := l.index[childID]
+		if node.Column < child.getPathPoint(node.ID, -2).X {
+			if !child.isPathSubBranch(node.ID) &&
+				!(child.hasOlderParent(node.Idx) && child.getPathPoint(node.ID, 1).Type == MERGE_TO) {
+				l.nextColumn--
+				log.WithFields(log.Fields{
+					"nextColumn": l.nextColumn,
+					"operator":   "--",
+					"merging":    child.ID,
+					"into":       node.ID,
+					"sub":        child.isPathSubBranch(node.ID),
+				}).Debug("node merging --")
+				l.releaseColor(child.getPathColor(node.ID), node.Idx)
+			}
+
+			if !child.firstInRow && !child.isPathSubBranch(node.ID) && !child.hasOlderParent(node.Idx) {
+				child.setPathColor(node.ID, child.Color)
+			}
+			l.releaseColor(child.getPathColor(node.ID), node.Idx)
+
+			// Insert before the last element
+			pos := child.pathLength(node.ID) - 1
+			point := Point{child.getPathPoint(node.ID, -2).X, node.Idx, MERGE_BACK}
+			child.insert(node.ID, pos, point)
+
+			// Nodes that are following the current node
+			for followingNodeID := range l.followingNodesWithChildrenBeforeIdx.Items {
+				followingNode := l.index[followingNodeID]
+				if followingNode.Idx > node.Idx {
+					// Following nodes that have a child before the current node
+					for _, followingNodeChildID := range followingNode.children {
+						followingNodeChild := l.index[followingNodeChildID]
+						if followingNodeChild.Idx < node.Idx {
+							// Following node child has a path that is higher than the current path being merged
+							if followingNodeChild.GetPathHeightAtIdx(followingNode.ID, node.Idx) > child.getPathPoint(node.ID, -2).X {
+
+								// Index to delete is the one before last
+								idxRemove := followingNodeChild.pathLength(followingNode.ID) - 1
+								if idxRemove < 0 {
+									continue
+								}
+								// Remove second before last node has same Y, remove the before last node
+								for followingNodeChild.pathLength(followingNode.ID) > idxRemove &&
+									followingNodeChild.getPathPoint(followingNode.ID, idxRemove).Y == followingNodeChild.getPathPoint(followingNode.ID, idxRemove-1).Y {
+									followingNodeChild.remove(followingNode.ID, idxRemove-1)
+									idxRemove--
+								}
+
+								// Calculate nb of merging nodes
+								nbNodesMergingBack := 0
+								for _, childID := range node.children {
+									child := l.index[childID]
+									if node.Column < child.getPathPoint(node.ID, -2).X &&
+										child.getPathPoint(node.ID, -2).X < followingNodeChild.GetPathHeightAtIdx(followingNode.ID, node.Idx) &&
+										!child.isPathSubBranch(node.ID) &&
+										!(child.hasOlderParent(node.Idx) && child.getPathPoint(node.ID, 1).Type == MERGE_TO) {
+										nbNodesMergingBack++
 									}
-									tmp := followingNodeChild.getPathPoint(followingNode.ID, idxRemove-1).X
-									followingNodeChild.remove(followingNode.ID, idxRemove)
-									followingNodeChild.append(followingNode.ID, Point{tmp, node.Idx, MERGE_BACK})
-									followingNodeChild.append(followingNode.ID, Point{tmp - 1 - (nbNodesMergingBack - 1), node.Idx, PIPE})
-									if followingNode.Column > child.getPathPoint(node.ID, -2).X {
-										if processedNodes[followingNode.ID] == nil {
-											followingNodeChild.append(followingNode.ID, Point{followingNode.Column - (nbNodesMergingBack - 1) - 1, followingNode.Idx, PIPE})
-											followingNode.Column -= nbNodesMergingBack
-										} else {
-											followingNodeChild.append(followingNode.ID, Point{followingNode.Column, followingNode.Idx, PIPE})
-										}
-										followingNode.addDebug(fmt.Sprintf("Column minus %s, %s, %d, %d", followingNode.ID, child.ID, followingNode.Column, nbNodesMergingBack))
+								}
+
+								if processedNodes[followingNode.ID] != nil && processedNodes[followingNode.ID][followingNodeChild.ID] {
+									continue
+								}
+								tmp := followingNodeChild.getPathPoint(followingNode.ID, idxRemove-1).X
+								followingNodeChild.remove(followingNode.ID, idxRemove)
+								followingNodeChild.append(followingNode.ID, Point{tmp, node.Idx, MERGE_BACK})
+								followingNodeChild.append(followingNode.ID, Point{tmp - 1 - (nbNodesMergingBack - 1), node.Idx, PIPE})
+								if followingNode.Column > child.getPathPoint(node.ID, -2).X {
+									if processedNodes[followingNode.ID] == nil {
+										followingNodeChild.append(followingNode.ID, Point{followingNode.Column - (nbNodesMergingBack - 1) - 1, followingNode.Idx, PIPE})
+										followingNode.Column -= nbNodesMergingBack
 									} else {
-										followingNodeChild.append(followingNode.ID, Point{tmp - 1 - (nbNodesMergingBack - 1), followingNode.Idx, MERGE_BACK})
 										followingNodeChild.append(followingNode.ID, Point{followingNode.Column, followingNode.Idx, PIPE})
 									}
-									if processedNodes[followingNode.ID] == nil {
-										processedNodes[followingNode.ID] = make(map[string]bool)
-									}
-									processedNodes[followingNode.ID][followingNodeChild.ID] = true
+									followingNode.addDebug(fmt.Sprintf("Column minus %s, %s, %d, %d", followingNode.ID, child.ID, followingNode.Column, nbNodesMergingBack))
+								} else {
+									followingNodeChild.append(followingNode.ID, Point{tmp - 1 - (nbNodesMergingBack - 1), followingNode.Idx, MERGE_BACK})
+									followingNodeChild.append(followingNode.ID, Point{followingNode.Column, followingNode.Idx, PIPE})
+								}
+								if processedNodes[followingNode.ID] == nil {
+									processedNodes[followingNode.ID] = make(map[string]bool)
 								}
+								processedNodes[followingNode.ID][followingNodeChild.ID] = true
 							}
 						}
 					}
 				}
 			}
 		}
+	}
 
-		for parentIdx, parentID := range node.Parents {
-			parent := index[parentID]
-
-			node.append(parent.ID, Point{node.Column, node.Idx, PIPE})
-
-			if !parent.columnDefined() {
-				if parentIdx == 0 || (parentIdx == 1 && index[node.Parents[0]].Column < node.Column && index[node.Parents[0]].Idx == node.Idx+1) {
-					parent.Column = node.Column
-					parent.addDebug(fmt.Sprintf("1- Column set to %d", node.Column))
-					parent.Color = node.Color
-					node.setPathColor(parent.ID, parent.Color)
-				} else {
-					parent.Column = nextColumn
-					parent.addDebug(fmt.Sprintf("2- Column set to %d", nextColumn))
-					parent.Color = getColor(node.Idx)
-					node.append(parent.ID, Point{parent.Column, node.Idx, FORK})
-					node.setPathColor(parent.ID, parent.Color)
-					node.firstInRow = true
-					nextColumn++
-					log.WithFields(log.Fields{
-						"nextColumn": nextColumn,
-						"operator":   "++",
-						"node":       node.ID,
-						"parent":     parent.ID,
-					}).Debug("new parent undefined column++")
+	for parentIdx, parentID := range node.Parents {
+		parent := l.index[parentID]
 
-				}
-			} else if parent.columnDefined() {
-				if node.Column < parent.Column && parentIdx == 0 {
-					for _, childID := range parent.children {
-						child := index[childID]
-						idxRemove := child.pathLength(parent.ID) - 1
-						if idxRemove > 0 {
-							if child.getPathPoint(parent.ID, idxRemove).Type != FORK {
-								child.remove(parent.ID, idxRemove)
-							}
-							pos := child.pathLength(parent.ID) - 1
-							child.append(parent.ID, Point{child.getPathPoint(parent.ID, pos).X, parent.Idx, MERGE_BACK})
-							child.append(parent.ID, Point{node.Column, parent.Idx, PIPE})
+		node.append(parent.ID, Point{node.Column, node.Idx, PIPE})
+
+		if !parent.columnDefined() {
+			if parentIdx == 0 || (parentIdx == 1 && l.index[node.Parents[0]].Column < node.Column && l.index[node.Parents[0]].Idx == node.Idx+1) {
+				parent.Column = node.Column
+				parent.addDebug(fmt.Sprintf("1- Column set to %d", node.Column))
+				parent.Color = node.Color
+				node.setPathColor(parent.ID, parent.Color)
+			} else {
+				parent.Column = l.nextColumn
+				parent.addDebug(fmt.Sprintf("2- Column set to %d", l.nextColumn))
+				parent.Color = l.getColor(parent, node.Idx)
+				node.append(parent.ID, Point{parent.Column, node.Idx, FORK})
+				node.setPathColor(parent.ID, parent.Color)
+				node.firstInRow = true
+				l.nextColumn++
+				log.WithFields(log.Fields{
+					"nextColumn": l.nextColumn,
+					"operator":   "++",
+					"node":       node.ID,
+					"parent":     parent.ID,
+				}).Debug("new parent undefined column++")
+
+			}
+		} else if parent.columnDefined() {
+			if node.Column < parent.Column && parentIdx == 0 {
+				for _, childID := range parent.children {
+					child := l.index[childID]
+					idxRemove := child.pathLength(parent.ID) - 1
+					if idxRemove > 0 {
+						if child.getPathPoint(parent.ID, idxRemove).Type != FORK {
+							child.remove(parent.ID, idxRemove)
 						}
+						pos := child.pathLength(parent.ID) - 1
+						child.append(parent.ID, Point{child.getPathPoint(parent.ID, pos).X, parent.Idx, MERGE_BACK})
+						child.append(parent.ID, Point{node.Column, parent.Idx, PIPE})
 					}
-					parent.Column = node.Column
-					parent.addDebug(fmt.Sprintf("Column reset to %d", node.Column))
-					parent.Color = node.Color
-					node.setPathColor(parent.ID, node.Color)
-				} else if node.Column < parent.Column && parentIdx > 0 {
-					node.setPathSubBranch(parent.ID)
-					node.append(parent.ID, Point{parent.Column, node.Idx, FORK})
-					node.setPathColor(parent.ID, parent.Color)
-				} else if node.Column > parent.Column {
-					if len(node.Parents) > 1 {
-						if node.hasBiggerParentDefined() || (parentIdx == 0 && parent.Idx > node.Idx+1) {
-							node.append(parent.ID, Point{node.Column, parent.Idx, MERGE_BACK})
-							node.setPathColor(parent.ID, node.Color)
-						} else {
-							node.append(parent.ID, Point{parent.Column, node.Idx, MERGE_TO})
-							node.setPathColor(parent.ID, parent.Color)
-						}
+				}
+				parent.Column = node.Column
+				parent.addDebug(fmt.Sprintf("Column reset to %d", node.Column))
+				parent.Color = node.Color
+				node.setPathColor(parent.ID, node.Color)
+			} else if node.Column < parent.Column && parentIdx > 0 {
+				node.setPathSubBranch(parent.ID)
+				node.append(parent.ID, Point{parent.Column, node.Idx, FORK})
+				node.setPathColor(parent.ID, parent.Color)
+			} else if node.Column > parent.Column {
+				if len(node.Parents) > 1 {
+					if node.hasBiggerParentDefined() || (parentIdx == 0 && parent.Idx > node.Idx+1) {
+						node.append(parent.ID, Point{node.Column, parent.Idx, MERGE_BACK})
+						node.setPathColor(parent.ID, node.Color)
+					} else {
+						node.append(parent.ID, Point{parent.Column, node.Idx, MERGE_TO})
+						node.setPathColor(parent.ID, parent.Color)
 					}
 				}
 			}
+		}
 
-			node.append(parent.ID, Point{parent.Column, parent.Idx, PIPE})
+		node.append(parent.ID, Point{parent.Column, parent.Idx, PIPE})
 
-		}
 	}
+}
 
-	// Deduplicate path nodes
-	for _, node := range nodes {
-		for parentID, path := range node.parentsPaths {
-			previousPoint := Point{-1, -1, -1}
-			for pointIdx := len(path.Path) - 1; pointIdx >= 0; pointIdx-- {
-				point := path.Path[pointIdx]
-				if point.X == previousPoint.X && point.Y == previousPoint.Y && point.Type == previousPoint.Type {
-					parentPath := node.parentsPaths[parentID]
-					parentPath.Path = append(parentPath.Path[:pointIdx], parentPath.Path[pointIdx+1:]...)
-					node.parentsPaths[parentID] = parentPath
-				}
-				previousPoint = point
+// finalize deduplicate the path nodes and order the paths like the parents (first parent first)
+func (node *OutputNode) finalize() {
+	for parentID, path := range node.parentsPaths {
+		previousPoint := Point{-1, -1, -1}
+		for pointIdx := len(path.Path) - 1; pointIdx >= 0; pointIdx-- {
+			point := path.Path[pointIdx]
+			if point.X == previousPoint.X && point.Y == previousPoint.Y && point.Type == previousPoint.Type {
+				parentPath := node.parentsPaths[parentID]
+				parentPath.Path = append(parentPath.Path[:pointIdx], parentPath.Path[pointIdx+1:]...)
+				node.parentsPaths[parentID] = parentPath
 			}
+			previousPoint = point
+		}
+	}
+	for _, parentID := range node.Parents {
+		if path, ok := node.parentsPaths[parentID]; ok {
+			node.FinalParentsPaths = append(node.FinalParentsPaths, Path{parentID, path.Path, path.Color})
 		}
 	}
 }
 
 // Get TODO
 func Get(inputNodes []map[string]interface{}) ([]map[string]interface{}, error) {
-	myColors := DefaultColors
-	nodes, err := BuildTree(inputNodes, myColors)
-	for _, node := range nodes {
-		delete(node, "parentsPaths")
-	}
-	return nodes, err
+	return NewLayout(Options{Colors: DefaultColors, Debug: DebugMode}).Get(inputNodes)
 }
 
 // GetPaginated TODO
 func GetPaginated(inputNodes []map[string]interface{}, from, size int) ([]map[string]interface{}, error) {
-	myColors := DefaultColors
-	nodes, err := BuildTree(inputNodes, myColors)
-	for _, node := range nodes {
-		delete(node, "parentsPaths")
-	}
-	return nodes[from : from+size], err
+	return NewLayout(Options{Colors: DefaultColors, Debug: DebugMode}).GetPaginated(inputNodes, from, size)
+}
+
+// GetPage build the tree with the default colors and return the page starting at cursor
+func GetPage(inputNodes []map[string]interface{}, cursor string, size int) (*Page, error) {
+	return NewLayout(Options{Colors: DefaultColors, Debug: DebugMode}).GetPage(inputNodes, cursor, size)
 }
 
 // BuildTree TODO
 func BuildTree(inputNodes []map[string]interface{}, myColors []Color) ([]map[string]interface{}, error) {
-	colors = make([]Color, 0)
-	for _, color := range myColors {
-		colors = append(colors, color)
-	}
+	return NewLayout(Options{Colors: myColors, Debug: DebugMode}).BuildTree(inputNodes)
+}
 
-	nodes := initNodes(inputNodes)
-	index = initIndex(nodes)
+// StreamTree lay out the nodes and call fn with every node as soon as it is final
+func StreamTree(inputNodes []map[string]interface{}, myColors []Color, fn func(map[string]interface{}) error) error {
+	return NewLayout(Options{Colors: myColors, Debug: DebugMode}).StreamTree(inputNodes, fn)
+}
 
-	initChildren(nodes)
-	setColumns(nodes)
+// Build typed version of Get, using the default colors
+func Build(inputNodes []InputNode) ([]OutputNode, error) {
+	return NewLayout(Options{Colors: DefaultColors, Debug: DebugMode}).Build(inputNodes)
+}
 
+// Get build the tree and remove internal properties
+func (l *Layout) Get(inputNodes []map[string]interface{}) ([]map[string]interface{}, error) {
+	nodes, err := l.BuildTree(inputNodes)
 	for _, node := range nodes {
-		for parentID, path := range node.parentsPaths {
-			node.FinalParentsPaths = append(node.FinalParentsPaths, Path{parentID, path.Path, path.Color})
-		}
+		delete(node, "parentsPaths")
+	}
+	return nodes, err
+}
+
+// GetPaginated build the tree and return the nodes from the row from, at most size of them
+func (l *Layout) GetPaginated(inputNodes []map[string]interface{}, from, size int) ([]map[string]interface{}, error) {
+	if from < 0 || size < 0 {
+		return nil, fmt.Errorf("invalid page: from %d, size %d", from, size)
 	}
+	nodes, err := l.Get(inputNodes)
+	if err != nil {
+		return nil, err
+	}
+	if from > len(nodes) {
+		from = len(nodes)
+	}
+	end := from + size
+	if end > len(nodes) {
+		end = len(nodes)
+	}
+	return nodes[from:end], nil
+}
+
+// GetPage build the tree and return the page starting at cursor, see Paginate
+func (l *Layout) GetPage(inputNodes []map[string]interface{}, cursor string, size int) (*Page, error) {
+	nodes, err := l.Get(inputNodes)
+	if err != nil {
+		return nil, err
+	}
+	return Paginate(nodes, cursor, size)
+}
+
+// BuildTree compute the columns and paths of every node
+func (l *Layout) BuildTree(inputNodes []map[string]interface{}) ([]map[string]interface{}, error) {
+	l.reset()
+	nodes, err := l.initNodes(inputNodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.compute(nodes, nil); err != nil {
+		return nil, err
+	}
+
 	finalStruct := make([]map[string]interface{}, 0)
 	for _, node := range nodes {
-		finalNode := map[string]interface{}{}
-		for key, value := range node.InitialNode {
-			finalNode[key] = value
-		}
+		finalNode := l.toMap(node)
 		finalNode["parentsPaths"] = node.parentsPaths // Kept for tests
-		finalNode["id"] = node.ID
-		finalNode["parents"] = node.Parents
-		finalNode["column"] = node.Column
-		finalNode["parents_paths"] = node.FinalParentsPaths
-		finalNode["idx"] = node.Idx
-		finalNode["color"] = node.Color
-		if DebugMode {
-			finalNode["debug"] = node.Debug
-		}
 		finalStruct = append(finalStruct, finalNode)
 	}
 
 	return finalStruct, nil
 }
 
-// GetInputNodesFromFile TODO
-func GetInputNodesFromFile(filePath string) (nodes []map[string]interface{}, err error) {
-	fileBytes, err := ioutil.ReadFile(filePath)
+// StreamTree compute the columns and paths of every node like Get, fn is called with every node as soon as it is final.
+// A node is final once its last parent is laid out, so the nodes are not always in idx order.
+func (l *Layout) StreamTree(inputNodes []map[string]interface{}, fn func(map[string]interface{}) error) error {
+	l.reset()
+	if err := l.AppendStream(inputNodes, fn); err != nil {
+		return err
+	}
+	return l.Finish()
+}
+
+// AppendStream like Append, but fn is called with every node as soon as it is final, and the final nodes are not
+// kept by the layout. Reading the input by chunks (see ReadNDJSON) keeps in memory only the nodes of the open lanes.
+// Call Finish once every chunk is appended.
+func (l *Layout) AppendStream(inputNodes []map[string]interface{}, fn func(map[string]interface{}) error) error {
+	nodes, err := l.initNodes(inputNodes)
 	if err != nil {
-		return
+		return err
 	}
-	nodes, err = GetInputNodesFromJSON(fileBytes)
+	l.streamed = true
+	l.add(nodes)
+	return l.process(func(node *OutputNode) error {
+		err := fn(l.toMap(node))
+		// Final nodes are not read anymore by the layout
+		node.parentsPaths = nil
+		node.FinalParentsPaths = nil
+		node.InitialNode = nil
+		return err
+	})
+}
+
+// Append lay out a chunk of nodes older than the ones of the previous calls, and return the nodes that became final.
+// Nodes whose parents are in the next chunks are returned by the following calls, once laid out.
+// Appending every chunk gives the same nodes as a single call to Get with all of them.
+func (l *Layout) Append(inputNodes []map[string]interface{}) ([]map[string]interface{}, error) {
+	nodes, err := l.initNodes(inputNodes)
 	if err != nil {
-		return
+		return nil, err
 	}
-	return
+	l.add(nodes)
+	finalStruct := make([]map[string]interface{}, 0)
+	err = l.process(func(node *OutputNode) error {
+		finalStruct = append(finalStruct, l.toMap(node))
+		return nil
+	})
+	return finalStruct, err
+}
+
+// Pending number of nodes appended that are not final yet
+func (l *Layout) Pending() int {
+	pending := len(l.nodes) - l.processed
+	for _, nodes := range l.finalAt {
+		pending += len(nodes)
+	}
+	return pending
+}
+
+// Lane path crossing the top or the bottom border of a page
+type Lane struct {
+	ID       string `json:"id"` // Child, the path starts at this node
+	ParentID string `json:"parent_id"`
+	Column   int    `json:"column"` // Column of the path at the border
+	Color    string `json:"color"`
 }
 
-func deleteEmpty(s []string) []string {
-	r := make([]string, 0)
-	for _, str := range s {
-		if str != "" {
-			r = append(r, str)
+// Segment part of a path crossing a page, clipped to the row before and the row after the page
+type Segment struct {
+	ID       string  `json:"id"` // Child, the path starts at this node
+	ParentID string  `json:"parent_id"`
+	Path     []Point `json:"path"`
+	Color    string  `json:"color"`
+}
+
+// Page page of the nodes laid out, with the lanes needed to draw the edges entering and leaving it
+type Page struct {
+	Nodes    []map[string]interface{} `json:"nodes"`
+	Entering []Lane                   `json:"entering"`       // Paths from the nodes before the page to the page and after it
+	Leaving  []Lane                   `json:"leaving"`        // Paths from the page and before it to the nodes after the page
+	Segments []Segment                `json:"segments"`       // Entering and leaving paths, clipped
+	Next     string                   `json:"next,omitempty"` // Cursor of the next page, empty on the last page
+
+	context []map[string]interface{} // Nodes before the page with paths crossing it
+	start   int
+}
+
+// Cursor cursor of the page starting at the node id
+func Cursor(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// Paginate return at most size nodes laid out (output of Get), starting at the node of cursor.
+// An empty cursor is the first page.
+func Paginate(nodes []map[string]interface{}, cursor string, size int) (*Page, error) {
+	start := 0
+	if cursor != "" {
+		id, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, fmt.Errorf("invalid cursor %s", cursor)
+		}
+		start = -1
+		for idx, node := range nodes {
+			if node["id"] == string(id) {
+				start = idx
+				break
+			}
+		}
+		if start == -1 {
+			return nil, fmt.Errorf("invalid cursor: unknown node %s", id)
 		}
 	}
-	return r
+	return Window(nodes, start, size)
 }
 
-// GetInputNodesFromRepo TODO
-func GetInputNodesFromRepo(seqIds bool) (nodes []map[string]interface{}, err error) {
-	startOfCommit := "@@@@@@@@@@"
-	outBytes, err := exec.Command("git", "log", "--pretty=tformat:"+startOfCommit+"%n%H%n%aN%n%aE%n%at%n%ai%n%P%n%T%n%s", "--date=local", "--branches", "--remotes").Output()
-	if err != nil {
-		return
+// Window return at most size nodes laid out (output of Get), starting at the row start
+func Window(nodes []map[string]interface{}, start, size int) (*Page, error) {
+	if start < 0 || size < 1 {
+		return nil, fmt.Errorf("invalid window: start %d, size %d", start, size)
 	}
-	outString := string(outBytes)
-	lines := strings.Split(outString, "\n")
-	ids := 0
-	shaMap := make(map[string]string)
-	i := 0
-	for i < len(lines) {
-		if i >= len(lines) {
-			break
+	if start > len(nodes) {
+		start = len(nodes)
+	}
+	end := start + size
+	if end > len(nodes) {
+		end = len(nodes)
+	}
+
+	page := &Page{Nodes: nodes[start:end], Entering: make([]Lane, 0), Leaving: make([]Lane, 0), Segments: make([]Segment, 0), start: start}
+	for _, node := range nodes[:end] {
+		var segments []Path
+		for _, path := range node["parents_paths"].([]Path) {
+			last := path.Path[len(path.Path)-1]
+			lane := Lane{ID: node["id"].(string), ParentID: path.ID, Color: path.Color}
+			entering := node["idx"].(int) < start && last.Y >= start
+			leaving := last.Y >= end
+			if entering {
+				// First point in the page
+				for _, point := range path.Path {
+					if point.Y >= start {
+						lane.Column = point.X
+						break
+					}
+				}
+				page.Entering = append(page.Entering, lane)
+			}
+			if leaving {
+				// Last point in the page
+				for _, point := range path.Path {
+					if point.Y >= end {
+						break
+					}
+					lane.Column = point.X
+				}
+				page.Leaving = append(page.Leaving, lane)
+			}
+			if entering || leaving {
+				clipped := clip(path.Path, start-1, end)
+				page.Segments = append(page.Segments, Segment{lane.ID, lane.ParentID, clipped, lane.Color})
+				segments = append(segments, Path{path.ID, clipped, path.Color})
+			}
 		}
-		i++
-		sha := lines[i]
-		//name := lines[i+1]
-		//email := lines[i+2]
-		//date := lines[i+3]
-		//dateIso := lines[i+4]
-		parents := strings.Split(lines[i+5], " ")
-		parents = deleteEmpty(parents)
-		//tree := lines[i+6]
-		//subject := lines[i+7]
-		i += 8
-		node := map[string]interface{}{}
-		if seqIds {
-			id := strconv.Itoa(ids)
-			shaMap[sha] = id
-			node["id"] = id
-		} else {
-			node["id"] = sha
-		}
-		node["parents"] = parents
-		nodes = append(nodes, node)
-		ids++
-		if lines[i] != startOfCommit {
-			break
+		if segments != nil && node["idx"].(int) < start {
+			row := map[string]interface{}{"context": true, "parents_paths": segments}
+			for _, key := range []string{"id", "parents", "column", "idx", "color"} {
+				row[key] = node[key]
+			}
+			page.context = append(page.context, row)
+		}
+	}
+	if end < len(nodes) {
+		page.Next = Cursor(nodes[end]["id"].(string))
+	}
+	return page, nil
+}
+
+// clip keep the part of the path between the rows top and bottom. The points outside are moved to the closest
+// border, which keeps the shape of the path since the paths are vertical between two rows.
+func clip(path []Point, top, bottom int) []Point {
+	out := make([]Point, 0, len(path))
+	for i, point := range path {
+		if point.Y < top {
+			if i+1 == len(path) || path[i+1].Y <= top {
+				continue
+			}
+			point = Point{point.X, top, PIPE}
+		} else if point.Y > bottom {
+			if i == 0 || path[i-1].Y >= bottom {
+				continue
+			}
+			point = Point{point.X, bottom, PIPE}
+		}
+		out = append(out, point)
+	}
+	return out
+}
+
+// Rows rows to draw the page: the nodes before the page with paths crossing it, marked "context" and with only
+// the clipped paths, followed by the nodes of the page with their paths clipped
+func (p *Page) Rows() []map[string]interface{} {
+	rows := make([]map[string]interface{}, 0, len(p.context)+len(p.Nodes))
+	rows = append(rows, p.context...)
+	for _, node := range p.Nodes {
+		row := make(map[string]interface{}, len(node))
+		for key, value := range node {
+			row[key] = value
+		}
+		paths := make([]Path, 0)
+		for _, path := range node["parents_paths"].([]Path) {
+			paths = append(paths, Path{path.ID, clip(path.Path, p.start-1, p.start+len(p.Nodes)), path.Color})
 		}
+		row["parents_paths"] = paths
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// RowChange row of the layout changed by Prepend.
+// Rows that are not listed only moved down by the number of prepended nodes.
+type RowChange struct {
+	ID     string `json:"id"`
+	Idx    int    `json:"idx"`     // Row in the updated layout
+	OldIdx int    `json:"old_idx"` // Row in the previous layout, -1 for the nodes not returned before
+	Column bool   `json:"column"`  // The node moved to another lane
+	Color  bool   `json:"color"`
+	Paths  bool   `json:"paths"` // Paths to the parents changed, other than moving down
+}
+
+// Prepend lay out nodes newer than the ones of the previous calls (eg: after a fetch) on top of them.
+// It returns the final nodes of the updated layout and only the rows that changed.
+// It is not incremental: new lanes can shift every row below, so the whole layout is computed again and every
+// row is compared with the previous one. The rows still pending after Append are not compared, they were never
+// returned. The rows of StreamTree are dropped once final, a streamed layout can not be prepended to.
+func (l *Layout) Prepend(inputNodes []map[string]interface{}) ([]map[string]interface{}, []RowChange, error) {
+	if l.streamed {
+		return nil, nil, fmt.Errorf("can not prepend to a layout built by StreamTree, its rows are not kept")
+	}
+	previous := l.nodes
+	wasPending := make(map[*OutputNode]bool)
+	for _, node := range previous[l.processed:] {
+		wasPending[node] = true
 	}
-	if seqIds {
+	for _, nodes := range l.finalAt {
 		for _, node := range nodes {
-			mappedParents := make([]string, 0)
-			for _, parentSha := range node["parents"].([]string) {
-				mappedParents = append(mappedParents, shaMap[parentSha])
+			wasPending[node] = true
+		}
+	}
+	inputs := make([]map[string]interface{}, 0, len(inputNodes)+len(previous))
+	inputs = append(inputs, inputNodes...)
+	for _, node := range previous {
+		input := make(map[string]interface{}, len(node.InitialNode)+2)
+		for key, value := range node.InitialNode {
+			input[key] = value
+		}
+		input["id"] = node.ID
+		input["parents"] = append([]string{}, node.Parents...)
+		inputs = append(inputs, input)
+	}
+
+	l.reset()
+	nodes, err := l.initNodes(inputs)
+	if err != nil {
+		return nil, nil, err
+	}
+	l.add(nodes)
+	if err := l.process(nil); err != nil {
+		return nil, nil, err
+	}
+	pending := make(map[*OutputNode]bool)
+	for _, nodes := range l.finalAt {
+		for _, node := range nodes {
+			pending[node] = true
+		}
+	}
+
+	offset := len(inputNodes)
+	finalStruct := make([]map[string]interface{}, 0)
+	changes := make([]RowChange, 0)
+	for _, node := range l.nodes[:l.processed] {
+		if pending[node] {
+			continue
+		}
+		finalStruct = append(finalStruct, l.toMap(node))
+		if node.Idx < offset || wasPending[previous[node.Idx-offset]] {
+			changes = append(changes, RowChange{ID: node.ID, Idx: node.Idx, OldIdx: -1, Column: true, Color: true, Paths: true})
+			continue
+		}
+		old := previous[node.Idx-offset]
+		change := RowChange{ID: node.ID, Idx: node.Idx, OldIdx: old.Idx}
+		change.Column = node.Column != old.Column
+		change.Color = node.Color != old.Color
+		change.Paths = !samePaths(old.FinalParentsPaths, node.FinalParentsPaths, offset)
+		if change.Column || change.Color || change.Paths {
+			changes = append(changes, change)
+		}
+	}
+	return finalStruct, changes, nil
+}
+
+// samePaths compare paths, the points of the new paths are offset rows below the old ones
+func samePaths(old, new []Path, offset int) bool {
+	if len(old) != len(new) {
+		return false
+	}
+	for i := range old {
+		if old[i].ID != new[i].ID || old[i].Color != new[i].Color || len(old[i].Path) != len(new[i].Path) {
+			return false
+		}
+		for j, point := range old[i].Path {
+			point.Y += offset
+			if point != new[i].Path[j] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (l *Layout) toMap(node *OutputNode) map[string]interface{} {
+	finalNode := map[string]interface{}{}
+	for key, value := range node.InitialNode {
+		finalNode[key] = value
+	}
+	finalNode["id"] = node.ID
+	finalNode["parents"] = node.Parents
+	finalNode["column"] = node.Column
+	finalNode["parents_paths"] = node.FinalParentsPaths
+	finalNode["idx"] = node.Idx
+	finalNode["color"] = node.Color
+	if l.debug {
+		finalNode["debug"] = node.Debug
+	}
+	return finalNode
+}
+
+// Build typed version of BuildTree
+func (l *Layout) Build(inputNodes []InputNode) ([]OutputNode, error) {
+	l.reset()
+	nodes, err := l.initTypedNodes(inputNodes)
+	if err != nil {
+		return nil, err
+	}
+	if err := l.compute(nodes, nil); err != nil {
+		return nil, err
+	}
+
+	out := make([]OutputNode, 0, len(nodes))
+	for _, node := range nodes {
+		if !l.debug {
+			node.Debug = nil
+		}
+		out = append(out, *node)
+	}
+	return out, nil
+}
+
+// compute lay out all the nodes, final is called with every node as soon as it is final
+func (l *Layout) compute(nodes []*OutputNode, final func(*OutputNode) error) error {
+	l.add(nodes)
+	if err := l.process(final); err != nil {
+		return err
+	}
+	return l.Finish()
+}
+
+// Finish check that every node appended is laid out, a node waiting for a parent that was never appended is an error
+func (l *Layout) Finish() error {
+	if l.processed < len(l.nodes) {
+		node := l.nodes[l.processed]
+		for _, parentID := range node.Parents {
+			if l.index[parentID] == nil {
+				return &InputError{node.Idx, "parents", fmt.Sprintf("contains %s which is not one of the nodes", parentID)}
 			}
-			node["parents"] = mappedParents
 		}
 	}
+	return nil
+}
+
+// GetInputNodesFromFile TODO
+func GetInputNodesFromFile(filePath string) (nodes []map[string]interface{}, err error) {
+	fileBytes, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return
+	}
+	nodes, err = GetInputNodesFromJSON(fileBytes)
+	if err != nil {
+		return
+	}
 	return
 }
+
+// GetInputNodesFromReader Get nodes from the json read from r (eg: os.Stdin)
+func GetInputNodesFromReader(r io.Reader) (nodes []map[string]interface{}, err error) {
+	inputBytes, err := ioutil.ReadAll(r)
+	if err != nil {
+		return
+	}
+	return GetInputNodesFromJSON(inputBytes)
+}
@@ -1,14 +1,20 @@
 package git2graph
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
 	"testing"
 )
 
 func validateColumns(t *testing.T, expectedColumns []int, data []map[string]interface{}) {
 	for idx, row := range data {
 		if row["column"] != expectedColumns[idx] {
-			t.Fail()
-			t.Logf("ID: %s, Expected column: %d, Actual column: %d", row["id"], expectedColumns[idx], row["column"])
+			t.Errorf("ID: %s, Expected column: %d, Actual column: %d", row["id"], expectedColumns[idx], row["column"])
 		}
 	}
 }
@@ -20,15 +26,13 @@ func validatePaths(t *testing.T, expectedPaths []map[string]Path, data []map[str
 				continue
 			}
 			if len(node["parentsPaths"].(map[string]Path)[parentID].Path) != len(expectedPaths[nodeIdx][parentID].Path) {
-				t.Fail()
-				t.Logf("ID: %s, Expected nb paths: %d, Actual nb paths: %d", node["id"], len(expectedPaths[nodeIdx][parentID].Path), len(node["parentsPaths"].(map[string]Path)[parentID].Path))
+				t.Errorf("ID: %s, Expected nb paths: %d, Actual nb paths: %d", node["id"], len(expectedPaths[nodeIdx][parentID].Path), len(node["parentsPaths"].(map[string]Path)[parentID].Path))
 				t.Logf("ID: %s, Expected: %v, Actual: %v", node["id"], expectedPaths[nodeIdx][parentID], node["parentsPaths"].(map[string]Path)[parentID])
 				return
 			}
 			for pathIdx, pathNode := range node["parentsPaths"].(map[string]Path)[parentID].Path {
 				if pathNode != expectedPaths[nodeIdx][parentID].Path[pathIdx] {
-					t.Fail()
-					t.Logf("ID: %s, Expected path: %d, Actual path: %d", node["id"], expectedPaths[nodeIdx][parentID].Path[pathIdx], pathNode)
+					t.Errorf("ID: %s, Expected path: %d, Actual path: %d", node["id"], expectedPaths[nodeIdx][parentID].Path[pathIdx], pathNode)
 					t.Logf("ID: %s, Expected: %v, Actual: %v", node["id"], expectedPaths[nodeIdx][parentID].Path, node["parentsPaths"].(map[string]Path)[parentID].Path)
 				}
 			}
@@ -43,8 +47,7 @@ func validateColors(t *testing.T, expectedPaths []map[string]Path, data []map[st
 				continue
 			}
 			if expectedPaths[nodeIdx][parentID].Color != node["parentsPaths"].(map[string]Path)[parentID].Color {
-				t.Logf("ID: %s, Expected: %v, Actual: %v", node["id"], expectedPaths[nodeIdx][parentID].Color, node["parentsPaths"].(map[string]Path)[parentID].Color)
-				t.Fail()
+				t.Errorf("ID: %s, Expected: %v, Actual: %v", node["id"], expectedPaths[nodeIdx][parentID].Color, node["parentsPaths"].(map[string]Path)[parentID].Color)
 			}
 		}
 	}
@@ -63,8 +66,23 @@ var customColors = []Color{
 	Color{-2, "color10", false},
 }
 
+// mergingNodes 8 nodes with 4 lanes merging back, new maps on every call since the layout changes its input
+func mergingNodes() []map[string]interface{} {
+	return []map[string]interface{}{
+		{"id": "0", "parents": []string{"4"}},
+		{"id": "1", "parents": []string{"5"}},
+		{"id": "2", "parents": []string{"6", "3"}},
+		{"id": "3", "parents": []string{"7"}},
+		{"id": "4", "parents": []string{"5", "6"}},
+		{"id": "5", "parents": []string{"7"}},
+		{"id": "6", "parents": []string{"7"}},
+		{"id": "7", "parents": []string{}},
+	}
+}
+
 func TestDebug(t *testing.T) {
 	DebugMode = true
+	defer func() { DebugMode = false }()
 	inputNodes := make([]map[string]interface{}, 0)
 	inputNodes = append(inputNodes, map[string]interface{}{"id": "1", "parents": []string{"2"}})
 	inputNodes = append(inputNodes, map[string]interface{}{"id": "2", "parents": []string{"3"}})
@@ -72,7 +90,7 @@ func TestDebug(t *testing.T) {
 	out, _ := BuildTree(inputNodes, customColors)
 	// Ensure nodes have debug property
 	if len(out[0]["debug"].([]string)) <= 0 {
-		t.Fail()
+		t.Error("Expected the debug property")
 	}
 }
 
@@ -88,13 +106,13 @@ func TestNotEnoughColors(t *testing.T) {
 	inputNodes = append(inputNodes, map[string]interface{}{"id": "3", "parents": []string{}})
 	out, _ := BuildTree(inputNodes, colors)
 	if out[2]["color"] != "#000" {
-		t.Fail()
+		t.Errorf("Expected: #000, Actual: %s", out[2]["color"])
 	}
 }
 
 func TestGetInputNodesFromJson(t *testing.T) {
 	json := `[{"id": "1", "parents": ["2"]}, {"id": "2", "parents": ["3"]}, {"id": "3", "parents": []}]`
-	inputNodes, _ := GetInputNodesFromJSON(json)
+	inputNodes, _ := GetInputNodesFromJSON([]byte(json))
 	out, _ := BuildTree(inputNodes, customColors)
 
 	// Expected output
@@ -117,9 +135,114 @@ func TestGetInputNodesFromJson(t *testing.T) {
 
 func TestGetInputNodesFromJsonWithBadJson(t *testing.T) {
 	json := `[{"id": "1", "parents": ["2"]}, {"id": "2", "parents": ["3"]}, {"id": "3", "parents": []}`
-	_, err := GetInputNodesFromJSON(json)
+	_, err := GetInputNodesFromJSON([]byte(json))
 	if err == nil {
-		t.Fail()
+		t.Error("Expected an error")
+	}
+}
+
+func TestGetInputNodesFromJsonMalformedNodes(t *testing.T) {
+	inputs := map[string]InputError{
+		`[{"id": "1", "parents": ["2"]}, {"id": "2"}]`:              InputError{1, "parents", "property is missing"},
+		`[{"id": "1", "parents": "2"}, {"id": "2", "parents": []}]`: InputError{0, "parents", "property must be an array of string"},
+		`[{"id": "1", "parents": [2]}, {"id": "2", "parents": []}]`: InputError{0, "parents", "property must be an array of string"},
+	}
+	for json, expected := range inputs {
+		_, err := GetInputNodesFromJSON([]byte(json))
+		inputErr, ok := err.(*InputError)
+		if !ok || *inputErr != expected {
+			t.Errorf("Input: %s, Expected: %v, Actual: %v", json, expected, err)
+		}
+	}
+}
+
+func TestBuildTreeMalformedNodes(t *testing.T) {
+	inputNodes := make([]map[string]interface{}, 0)
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "1", "parents": []string{"2"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": 2, "parents": []string{}})
+	_, err := BuildTree(inputNodes, customColors)
+	inputErr, ok := err.(*InputError)
+	if !ok || inputErr.Idx != 1 || inputErr.Field != "id" {
+		t.Errorf("Expected id error on node 1, Actual: %v", err)
+	}
+
+	inputNodes[1] = map[string]interface{}{"id": "2", "parents": []interface{}{"3"}}
+	_, err = GetPaginated(inputNodes, 0, 1)
+	inputErr, ok = err.(*InputError)
+	if !ok || inputErr.Idx != 1 || inputErr.Field != "parents" {
+		t.Errorf("Expected parents error on node 1, Actual: %v", err)
+	}
+}
+
+func TestBuildTyped(t *testing.T) {
+	inputNodes := []InputNode{
+		{ID: "1", Parents: []string{"3"}, Attrs: map[string]interface{}{"team": "core"}},
+		{ID: "2", Parents: []string{"3"}},
+		{ID: "3", Parents: []string{}},
+	}
+	out, err := NewLayout(Options{Colors: customColors}).Build(inputNodes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Expected output
+	expectedColumns := []int{0, 1, 0}
+
+	expectedPaths := []map[string]Path{
+		map[string]Path{
+			"3": Path{"3", []Point{Point{0, 0, 0}, Point{0, 2, 0}}, "color1"},
+		},
+		map[string]Path{
+			"3": Path{"3", []Point{Point{1, 1, 0}, Point{1, 2, 1}, Point{0, 2, 0}}, "color2"},
+		},
+	}
+
+	// Validation
+	for idx, node := range out {
+		if node.Column != expectedColumns[idx] {
+			t.Errorf("ID: %s, Expected column: %d, Actual column: %d", node.ID, expectedColumns[idx], node.Column)
+		}
+		for _, path := range node.FinalParentsPaths {
+			if !reflect.DeepEqual(path, expectedPaths[idx][path.ID]) {
+				t.Errorf("ID: %s, Expected: %v, Actual: %v", node.ID, expectedPaths[idx][path.ID], path)
+			}
+		}
+	}
+	if out[0].InitialNode["team"] != "core" {
+		t.Errorf("Attrs not kept in InitialNode: %v", out[0].InitialNode)
+	}
+}
+
+func TestDeterministicParentsPaths(t *testing.T) {
+	inputNodes := func() []map[string]interface{} {
+		nodes := make([]map[string]interface{}, 0)
+		nodes = append(nodes, map[string]interface{}{"id": "0", "parents": []string{"1", "2", "3"}})
+		nodes = append(nodes, map[string]interface{}{"id": "1", "parents": []string{"4"}})
+		nodes = append(nodes, map[string]interface{}{"id": "2", "parents": []string{"4"}})
+		nodes = append(nodes, map[string]interface{}{"id": "3", "parents": []string{"4"}})
+		nodes = append(nodes, map[string]interface{}{"id": "4", "parents": []string{}})
+		return nodes
+	}
+	var expected []byte
+	for i := 0; i < 100; i++ {
+		out, _ := Get(inputNodes())
+		actual, err := json.Marshal(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			expected = actual
+		} else if !bytes.Equal(expected, actual) {
+			t.Fatalf("Expected: %s, Actual: %s", expected, actual)
+		}
+	}
+
+	// Paths follow the parents order
+	out, _ := Get(inputNodes())
+	for idx, path := range out[0]["parents_paths"].([]Path) {
+		if path.ID != out[0]["parents"].([]string)[idx] {
+			t.Errorf("Expected path %d to be for parent %s, Actual: %s", idx, out[0]["parents"].([]string)[idx], path.ID)
+		}
 	}
 }
 
@@ -2009,26 +2132,426 @@ func TestPathHeight1(t *testing.T) {
 		Point{X: 2, Y: 11, Type: 1},
 		Point{X: 1, Y: 11, Type: 0},
 	}}}}
-	if out.GetPathHeightAtIdx("1", 1) != -1 {
-		t.Fail()
+	if height := out.GetPathHeightAtIdx("1", 1); height != -1 {
+		t.Errorf("Row 1, Expected: -1, Actual: %d", height)
+	}
+	if height := out.GetPathHeightAtIdx("1", 2); height != 3 {
+		t.Errorf("Row 2, Expected: 3, Actual: %d", height)
 	}
-	if out.GetPathHeightAtIdx("1", 2) != 3 {
-		t.Fail()
+	if height := out.GetPathHeightAtIdx("1", 3); height != 3 {
+		t.Errorf("Row 3, Expected: 3, Actual: %d", height)
 	}
-	if out.GetPathHeightAtIdx("1", 3) != 3 {
-		t.Fail()
+	if height := out.GetPathHeightAtIdx("1", 9); height != 2 {
+		t.Errorf("Row 9, Expected: 2, Actual: %d", height)
 	}
-	if out.GetPathHeightAtIdx("1", 9) != 2 {
-		t.Fail()
+	if height := out.GetPathHeightAtIdx("1", 10); height != 2 {
+		t.Errorf("Row 10, Expected: 2, Actual: %d", height)
 	}
-	if out.GetPathHeightAtIdx("1", 10) != 2 {
-		t.Fail()
+	if height := out.GetPathHeightAtIdx("1", 11); height != 1 {
+		t.Errorf("Row 11, Expected: 1, Actual: %d", height)
 	}
-	if out.GetPathHeightAtIdx("1", 11) != 1 {
-		t.Fail()
+	if height := out.GetPathHeightAtIdx("1", 1000); height != -1 {
+		t.Errorf("Row 1000, Expected: -1, Actual: %d", height)
+	}
+}
+
+func TestConcurrentLayouts(t *testing.T) {
+	// Layouts with different palettes run at the same time, each one keeps its own colors
+	palettes := [][]Color{customColors, DefaultColors}
+	expected := make([][]map[string]interface{}, 0)
+	for _, colors := range palettes {
+		out, _ := NewLayout(Options{Colors: colors}).BuildTree(mergingNodes())
+		expected = append(expected, out)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			out, err := NewLayout(Options{Colors: palettes[i%2]}).BuildTree(mergingNodes())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if !reflect.DeepEqual(out, expected[i%2]) {
+				t.Errorf("Layout %d differs from the sequential run: %v", i, out)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestGetInputNodesFromNDJSON(t *testing.T) {
+	ndjson := "{\"id\": \"1\", \"parents\": [\"2\"], \"subject\": \"Fix\"}\n{\"id\": \"2\", \"parents\": []}\n"
+	nodes, err := GetInputNodesFromNDJSON(strings.NewReader(ndjson))
+	if err != nil || len(nodes) != 2 || nodes[0]["subject"] != "Fix" || !reflect.DeepEqual(nodes[0]["parents"], []string{"2"}) {
+		t.Errorf("Expected 2 nodes, Actual: %v, %v", nodes, err)
+	}
+
+	inputs := map[string]InputError{
+		"{\"id\": \"1\", \"parents\": [\"2\"]}\n{\"id\": \"2\"}\n": InputError{1, "parents", "property is missing"},
+		"null\n": InputError{0, "node", "must be an object"},
+	}
+	for ndjson, expected := range inputs {
+		_, err := GetInputNodesFromNDJSON(strings.NewReader(ndjson))
+		inputErr, ok := err.(*InputError)
+		if !ok || *inputErr != expected {
+			t.Errorf("Input: %q, Expected: %v, Actual: %v", ndjson, expected, err)
+		}
 	}
-	if out.GetPathHeightAtIdx("1", 1000) != -1 {
-		t.Fail()
+	if _, err := GetInputNodesFromNDJSON(strings.NewReader("[1, 2]\n")); err == nil {
+		t.Error("Expected an error for a line that is not an object")
+	}
+}
+
+func TestSerializeOutput(t *testing.T) {
+	nodes, err := GetInputNodesFromReader(strings.NewReader(`[{"id": "1", "parents": ["2"]}, {"id": "2", "parents": []}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, _ := Get(nodes)
+	var buf bytes.Buffer
+	if err := SerializeOutput(&buf, out); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []OutputNode
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil || len(decoded) != 2 || decoded[0].ID != "1" {
+		t.Errorf("Expected the 2 nodes, Actual: %s, %v", buf.String(), err)
+	}
+
+	NoOutput = true
+	defer func() { NoOutput = false }()
+	buf.Reset()
+	if err := SerializeOutput(&buf, out); err != nil || buf.Len() != 0 {
+		t.Errorf("Expected no output, Actual: %s, %v", buf.String(), err)
+	}
+}
+
+func TestStreamTree(t *testing.T) {
+	expected, _ := NewLayout(Options{Colors: DefaultColors}).Get(mergingNodes())
+
+	// Node 0 is final once its parent 4 is laid out, node 3 once 7 is
+	expectedOrder := []int{0, 1, 2, 4, 3, 5, 6, 7}
+	order := make([]int, 0)
+	err := StreamTree(mergingNodes(), DefaultColors, func(node map[string]interface{}) error {
+		idx := node["idx"].(int)
+		order = append(order, idx)
+		if !reflect.DeepEqual(node, expected[idx]) {
+			t.Errorf("Expected: %v, Actual: %v", expected[idx], node)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, expectedOrder) {
+		t.Errorf("Expected: %v, Actual: %v", expectedOrder, order)
+	}
+
+	stop := fmt.Errorf("stop")
+	calls := 0
+	err = StreamTree(mergingNodes(), DefaultColors, func(node map[string]interface{}) error {
+		calls++
+		return stop
+	})
+	if err != stop || calls != 1 {
+		t.Errorf("Expected the error of the callback to stop the layout, Actual: %v after %d calls", err, calls)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	expected, _ := NewLayout(Options{Colors: DefaultColors}).Get(mergingNodes())
+
+	for size := 1; size <= len(expected); size++ {
+		nodes := mergingNodes()
+		layout := NewLayout(Options{Colors: DefaultColors})
+		out := make([]map[string]interface{}, len(expected))
+		for i := 0; i < len(nodes); i += size {
+			end := i + size
+			if end > len(nodes) {
+				end = len(nodes)
+			}
+			final, err := layout.Append(nodes[i:end])
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, node := range final {
+				idx := node["idx"].(int)
+				if idx >= end || out[idx] != nil {
+					t.Errorf("Chunks of %d, node %d returned twice or before its chunk", size, idx)
+				}
+				out[idx] = node
+			}
+		}
+		if layout.Pending() != 0 || !reflect.DeepEqual(out, expected) {
+			t.Errorf("Chunks of %d, Expected: %v, Actual: %v", size, expected, out)
+		}
+	}
+
+	// Nodes are laid out in order, node 3 and the following ones wait for the parent of 3
+	layout := NewLayout(Options{Colors: DefaultColors})
+	final, _ := layout.Append(mergingNodes()[:7])
+	if len(final) != 0 || layout.Pending() != 7 {
+		t.Errorf("Expected 7 pending nodes, Actual: %d final, %d pending", len(final), layout.Pending())
+	}
+	final, _ = layout.Append(mergingNodes()[7:])
+	if len(final) != 8 || layout.Pending() != 0 {
+		t.Errorf("Expected 8 final nodes, Actual: %d final, %d pending", len(final), layout.Pending())
+	}
+}
+
+func TestAppendStream(t *testing.T) {
+	expected, _ := NewLayout(Options{Colors: DefaultColors}).Get(mergingNodes())
+	var ndjson bytes.Buffer
+	enc := json.NewEncoder(&ndjson)
+	for _, node := range mergingNodes() {
+		enc.Encode(node)
+	}
+
+	// Batches of 3 lines, node 3 waits for its parent 7 in the third batch
+	layout := NewLayout(Options{Colors: DefaultColors})
+	out := make([]map[string]interface{}, len(expected))
+	batches := 0
+	err := ReadNDJSON(&ndjson, 3, func(batch []map[string]interface{}) error {
+		batches++
+		return layout.AppendStream(batch, func(node map[string]interface{}) error {
+			out[node["idx"].(int)] = node
+			return nil
+		})
+	})
+	if err != nil || layout.Finish() != nil || batches != 3 {
+		t.Fatalf("Expected 3 batches, Actual: %d, %v", batches, err)
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, out)
+	}
+
+	// Final nodes are dropped by the layout
+	if layout.nodes[0].InitialNode != nil || layout.Pending() != 0 {
+		t.Errorf("Expected the final nodes to be dropped")
+	}
+
+	layout = NewLayout(Options{Colors: DefaultColors})
+	layout.AppendStream(mergingNodes()[:7], func(map[string]interface{}) error { return nil })
+	if _, ok := layout.Finish().(*InputError); !ok {
+		t.Error("Expected an error for the missing parent 7")
+	}
+}
+
+func TestPrepend(t *testing.T) {
+	fetched := func() []map[string]interface{} {
+		nodes := make([]map[string]interface{}, 0)
+		nodes = append(nodes, map[string]interface{}{"id": "a", "parents": []string{"b", "2"}})
+		nodes = append(nodes, map[string]interface{}{"id": "b", "parents": []string{"0"}})
+		return nodes
+	}
+	expected, _ := NewLayout(Options{Colors: DefaultColors}).Get(append(fetched(), mergingNodes()...))
+
+	layout := NewLayout(Options{Colors: DefaultColors})
+	if _, err := layout.Get(mergingNodes()); err != nil {
+		t.Fatal(err)
+	}
+	out, changes, err := layout.Prepend(fetched())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(out, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, out)
+	}
+	if len(changes) < 2 || changes[0].ID != "a" || changes[0].OldIdx != -1 || changes[1].ID != "b" || changes[1].OldIdx != -1 {
+		t.Fatalf("Expected the prepended nodes first, Actual: %v", changes)
+	}
+	for _, change := range changes[2:] {
+		if change.OldIdx != change.Idx-2 || !(change.Column || change.Color || change.Paths) {
+			t.Errorf("Unexpected change: %v", change)
+		}
+	}
+	// Merging 2 into a new branch moves the lanes of 1 and 2
+	changed := make(map[string]bool)
+	for _, change := range changes {
+		changed[change.ID] = true
+	}
+	if !changed["1"] || !changed["2"] || changed["7"] {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+
+	// Nothing changes but the rows
+	layout = NewLayout(Options{Colors: DefaultColors})
+	layout.Get(mergingNodes())
+	_, changes, _ = layout.Prepend([]map[string]interface{}{{"id": "a", "parents": []string{"0"}}})
+	if len(changes) != 1 || changes[0].ID != "a" {
+		t.Errorf("Expected only the new node, Actual: %v", changes)
+	}
+
+	// The attributes of the previous rows are kept
+	nodes := mergingNodes()
+	nodes[7]["subject"] = "Initial commit"
+	layout = NewLayout(Options{Colors: DefaultColors})
+	layout.Get(nodes)
+	out, _, _ = layout.Prepend([]map[string]interface{}{{"id": "a", "parents": []string{"0"}}})
+	if out[8]["subject"] != "Initial commit" {
+		t.Errorf("Expected the subject of 7 to be kept, Actual: %v", out[8])
+	}
+
+	// Rows still pending after Append are not compared, and the next chunks are appended after the prepended nodes
+	nodes = mergingNodes()
+	layout = NewLayout(Options{Colors: DefaultColors})
+	rows, _ := layout.Append(nodes[:7])
+	pending := layout.Pending()
+	out, changes, err = layout.Prepend([]map[string]interface{}{{"id": "a", "parents": []string{"0"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != len(rows)+1 || layout.Pending() != pending {
+		t.Errorf("Expected %d rows and %d pending, Actual: %d rows and %d pending", len(rows)+1, pending, len(out), layout.Pending())
+	}
+	returned := make(map[string]bool)
+	for _, row := range rows {
+		returned[row["id"].(string)] = true
+	}
+	for _, change := range changes {
+		if change.ID != "a" && !returned[change.ID] {
+			t.Errorf("Unexpected change of a pending row: %v", change)
+		}
+	}
+	more, err := layout.Append(nodes[7:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ = NewLayout(Options{Colors: DefaultColors}).Get(append([]map[string]interface{}{{"id": "a", "parents": []string{"0"}}}, mergingNodes()...))
+	all := append(out, more...)
+	sort.Slice(all, func(i, j int) bool { return all[i]["idx"].(int) < all[j]["idx"].(int) })
+	if !reflect.DeepEqual(all, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, all)
+	}
+
+	layout = NewLayout(Options{Colors: DefaultColors})
+	layout.StreamTree(mergingNodes(), func(map[string]interface{}) error { return nil })
+	if _, _, err := layout.Prepend(fetched()); err == nil {
+		t.Error("Expected an error after StreamTree")
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	inputNodes := mergingNodes()
+	nodes, _ := NewLayout(Options{Colors: DefaultColors}).Get(inputNodes)
+
+	lanes := func(lanes []Lane) []string {
+		out := make([]string, 0)
+		for _, lane := range lanes {
+			out = append(out, lane.ID+"-"+lane.ParentID)
+		}
+		return out
+	}
+	out := make([]map[string]interface{}, 0)
+	leaving := make([]string, 0)
+	cursor := ""
+	for pages := 0; ; pages++ {
+		page, err := Paginate(nodes, cursor, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The lanes leaving a page enter the next one
+		if !reflect.DeepEqual(lanes(page.Entering), leaving) {
+			t.Errorf("Page %d, Expected entering lanes: %v, Actual: %v", pages, leaving, lanes(page.Entering))
+		}
+		out = append(out, page.Nodes...)
+		leaving = lanes(page.Leaving)
+		cursor = page.Next
+		if cursor == "" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(out, nodes) || len(leaving) != 0 {
+		t.Errorf("Expected: %v, Actual: %v", nodes, out)
+	}
+
+	page, _ := Paginate(nodes, Cursor("2"), 2)
+	if len(page.Nodes) != 2 || page.Nodes[0]["id"] != "2" || page.Next != Cursor("4") {
+		t.Errorf("Expected nodes 2 and 3, Actual: %v", page)
+	}
+	expected := []Lane{{"0", "4", 0, "#5aa1be"}, {"1", "5", 1, "#c065b8"}}
+	if !reflect.DeepEqual(page.Entering, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, page.Entering)
+	}
+	if _, err := Paginate(nodes, Cursor("8"), 2); err == nil {
+		t.Error("Expected an error for an unknown node")
+	}
+	if _, err := Paginate(nodes, "", 0); err == nil {
+		t.Error("Expected an error for an empty page")
+	}
+
+	rows, err := GetPaginated(inputNodes, 6, 5)
+	if err != nil || len(rows) != 2 {
+		t.Errorf("Expected the 2 last nodes, Actual: %v, %v", rows, err)
+	}
+	rows, err = GetPaginated(inputNodes, 10, 5)
+	if err != nil || len(rows) != 0 {
+		t.Errorf("Expected no nodes, Actual: %v, %v", rows, err)
+	}
+	if _, err := GetPaginated(inputNodes, -1, 5); err == nil {
+		t.Error("Expected an error for a negative index")
+	}
+}
+
+func TestWindow(t *testing.T) {
+	inputNodes := mergingNodes()
+	nodes, _ := NewLayout(Options{Colors: DefaultColors}).Get(inputNodes)
+
+	page, err := Window(nodes, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every lane crossing the rows 3 and 4, clipped to the rows 2 and 5
+	expected := []Segment{
+		{"0", "4", []Point{{0, 2, PIPE}, {0, 4, PIPE}}, "#5aa1be"},
+		{"1", "5", []Point{{1, 2, PIPE}, {1, 5, MERGE_BACK}, {0, 5, PIPE}}, "#c065b8"},
+		{"2", "6", []Point{{2, 2, PIPE}, {2, 5, MERGE_BACK}, {1, 5, PIPE}}, "#c0ab5f"},
+		{"2", "3", []Point{{2, 2, PIPE}, {3, 2, FORK}, {3, 3, PIPE}}, "#59bc95"},
+		{"3", "7", []Point{{3, 3, PIPE}, {3, 5, MERGE_BACK}, {2, 5, PIPE}}, "#59bc95"},
+		{"4", "5", []Point{{0, 4, PIPE}, {0, 5, PIPE}}, "#5aa1be"},
+		{"4", "6", []Point{{0, 4, PIPE}, {2, 4, FORK}, {2, 5, MERGE_BACK}, {1, 5, PIPE}}, "#c0ab5f"},
+	}
+	if !reflect.DeepEqual(page.Segments, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, page.Segments)
+	}
+
+	rows := page.Rows()
+	ids := make([]string, 0)
+	for _, row := range rows {
+		ids = append(ids, row["id"].(string))
+		if context, _ := row["context"].(bool); context != (row["idx"].(int) < 3) {
+			t.Errorf("Node %s, Expected context only before the window", row["id"])
+		}
+	}
+	if !reflect.DeepEqual(ids, []string{"0", "1", "2", "3", "4"}) {
+		t.Errorf("Expected the context nodes then the window, Actual: %v", ids)
+	}
+	if paths := rows[3]["parents_paths"].([]Path); !reflect.DeepEqual(paths[0].Path, expected[4].Path) {
+		t.Errorf("Expected: %v, Actual: %v", expected[4].Path, paths[0].Path)
+	}
+	// The nodes of the layout are not modified
+	if paths := nodes[3]["parents_paths"].([]Path); len(paths[0].Path) != 5 {
+		t.Errorf("Unexpected path: %v", paths[0].Path)
+	}
+
+	if page, _ := Window(nodes, 6, 5); len(page.Nodes) != 2 || len(page.Leaving) != 0 || page.Next != "" {
+		t.Errorf("Expected the 2 last nodes, Actual: %v", page)
+	}
+	if _, err := Window(nodes, -1, 5); err == nil {
+		t.Error("Expected an error for a negative row")
+	}
+}
+
+func TestBuildTreeMissingParent(t *testing.T) {
+	inputNodes := make([]map[string]interface{}, 0)
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "1", "parents": []string{"2"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "2", "parents": []string{"3"}})
+	_, err := BuildTree(inputNodes, customColors)
+	inputErr, ok := err.(*InputError)
+	if !ok || inputErr.Idx != 1 || inputErr.Field != "parents" {
+		t.Errorf("Expected parents error on node 1, Actual: %v", err)
 	}
 }
 
@@ -0,0 +1,898 @@
+package git2graph
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// nativeRepo repository read directly from its .git directory, without the git executable
+type nativeRepo struct {
+	gitDir    string // Per worktree directory (HEAD)
+	commonDir string // Shared directory (objects, refs)
+	objects   *objectStore
+	graph     *commitGraph      // nil when the repository has no commit-graph
+	refs      map[string]string // Full ref name -> object id
+	symRefs   map[string]string // Symbolic ref name -> target ref name
+	shallow   map[string]bool
+	commits   map[string]*nativeCommit
+}
+
+// nativeCommit commit object parsed by the native reader.
+// Commits found in the commit-graph only have their topology until loadMetadata is called.
+type nativeCommit struct {
+	repoCommit
+	commitTime int64
+	generation uint32 // 0 when the commit is not in the commit-graph
+	loaded     bool
+}
+
+var shaRegexp = regexp.MustCompile("^[0-9a-f]{40}$")
+var shortShaRegexp = regexp.MustCompile("^[0-9a-f]{4,40}$")
+
+// getInputNodesFromGitDir Get nodes by reading the repository .git directory, without running git.
+// It produces the same nodes as git log, except that .mailmap is not applied.
+func getInputNodesFromGitDir(opts RepoOptions) (nodes []map[string]interface{}, err error) {
+	repo, err := openNativeRepo(opts.Path)
+	if err != nil {
+		return
+	}
+	defer repo.close()
+	commits, err := repo.log(opts)
+	if err != nil {
+		return
+	}
+	nodes = commitsToNodes(commits, opts)
+	return
+}
+
+// findGitDir find the git directory of the repository containing path
+func findGitDir(path string) (string, error) {
+	if path == "" {
+		path = "."
+	}
+	dir, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	for {
+		dotGit := filepath.Join(dir, ".git")
+		if fi, err := os.Stat(dotGit); err == nil {
+			if fi.IsDir() {
+				return dotGit, nil
+			}
+			// Worktrees and submodules use a "gitdir: <path>" file
+			content, err := ioutil.ReadFile(dotGit)
+			if err != nil {
+				return "", err
+			}
+			gitDir := strings.TrimSpace(strings.TrimPrefix(string(content), "gitdir:"))
+			if !filepath.IsAbs(gitDir) {
+				gitDir = filepath.Join(dir, gitDir)
+			}
+			return gitDir, nil
+		}
+		// Bare repository
+		if isGitDir(dir) {
+			return dir, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("not a git repository: %s", path)
+		}
+		dir = parent
+	}
+}
+
+func isGitDir(dir string) bool {
+	if _, err := os.Stat(filepath.Join(dir, "HEAD")); err != nil {
+		return false
+	}
+	fi, err := os.Stat(filepath.Join(dir, "objects"))
+	return err == nil && fi.IsDir()
+}
+
+func openNativeRepo(path string) (*nativeRepo, error) {
+	gitDir, err := findGitDir(path)
+	if err != nil {
+		return nil, err
+	}
+	repo := &nativeRepo{gitDir: gitDir, commonDir: gitDir}
+	if commonDir, err := ioutil.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		repo.commonDir = strings.TrimSpace(string(commonDir))
+		if !filepath.IsAbs(repo.commonDir) {
+			repo.commonDir = filepath.Join(gitDir, repo.commonDir)
+		}
+	}
+	repo.objects, err = openObjectStore(filepath.Join(repo.commonDir, "objects"))
+	if err != nil {
+		return nil, err
+	}
+	// A commit-graph that can not be read is ignored, the commit objects are parsed instead
+	repo.graph, _ = openCommitGraph(filepath.Join(repo.commonDir, "objects"))
+	repo.commits = make(map[string]*nativeCommit)
+	repo.shallow = make(map[string]bool)
+	if shallow, err := ioutil.ReadFile(filepath.Join(repo.commonDir, "shallow")); err == nil {
+		for _, sha := range strings.Fields(string(shallow)) {
+			repo.shallow[sha] = true
+		}
+	}
+	if err = repo.loadRefs(); err != nil {
+		repo.close()
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (r *nativeRepo) close() {
+	r.objects.close()
+}
+
+// loadRefs read HEAD, packed-refs and the loose refs (loose refs take precedence)
+func (r *nativeRepo) loadRefs() error {
+	r.refs = make(map[string]string)
+	r.symRefs = make(map[string]string)
+	if f, err := os.Open(filepath.Join(r.commonDir, "packed-refs")); err == nil {
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := scanner.Text()
+			// Skip comments and peeled tags (^<sha>), tags are peeled when needed
+			if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "^") {
+				continue
+			}
+			fields := strings.Fields(line)
+			if len(fields) == 2 && shaRegexp.MatchString(fields[0]) {
+				r.refs[fields[1]] = fields[0]
+			}
+		}
+		f.Close()
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+	}
+	refsDir := filepath.Join(r.commonDir, "refs")
+	err := filepath.Walk(refsDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(r.commonDir, path)
+		if err != nil {
+			return err
+		}
+		r.readLooseRef(filepath.ToSlash(rel), path)
+		return nil
+	})
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	r.readLooseRef("HEAD", filepath.Join(r.gitDir, "HEAD"))
+	return nil
+}
+
+func (r *nativeRepo) readLooseRef(name, path string) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return
+	}
+	value := strings.TrimSpace(string(content))
+	if strings.HasPrefix(value, "ref: ") {
+		r.symRefs[name] = strings.TrimPrefix(value, "ref: ")
+		delete(r.refs, name)
+	} else if shaRegexp.MatchString(value) {
+		r.refs[name] = value
+	}
+}
+
+// resolveRef get the object id of a ref, following symbolic refs
+func (r *nativeRepo) resolveRef(name string) (string, bool) {
+	for i := 0; i < 10; i++ {
+		if sha, ok := r.refs[name]; ok {
+			return sha, true
+		}
+		target, ok := r.symRefs[name]
+		if !ok {
+			return "", false
+		}
+		name = target
+	}
+	return "", false
+}
+
+// refNames all the refs names (except HEAD), sorted
+func (r *nativeRepo) refNames(prefix string) []string {
+	names := make([]string, 0)
+	for name := range r.refs {
+		if name != "HEAD" && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	for name := range r.symRefs {
+		if name != "HEAD" && strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// peel follow annotated tags until a commit is found
+func (r *nativeRepo) peel(sha string) (string, error) {
+	for i := 0; i < 10; i++ {
+		if r.graph != nil {
+			if _, ok := r.graph.lookup(sha); ok {
+				return sha, nil
+			}
+		}
+		objType, data, err := r.objects.read(sha)
+		if err != nil {
+			return "", err
+		}
+		if objType == "commit" {
+			return sha, nil
+		}
+		if objType != "tag" {
+			return "", fmt.Errorf("%s is a %s, not a commit", sha, objType)
+		}
+		target := ""
+		for _, line := range strings.Split(string(data), "\n") {
+			if strings.HasPrefix(line, "object ") {
+				target = strings.TrimPrefix(line, "object ")
+				break
+			}
+		}
+		if target == "" {
+			return "", fmt.Errorf("tag %s: missing object", sha)
+		}
+		sha = target
+	}
+	return "", fmt.Errorf("%s: too many nested tags", sha)
+}
+
+// commit get the topology of a commit, from the commit-graph when possible
+func (r *nativeRepo) commit(sha string) (*nativeCommit, error) {
+	if commit, ok := r.commits[sha]; ok {
+		return commit, nil
+	}
+	if r.graph != nil && !r.shallow[sha] {
+		if pos, ok := r.graph.lookup(sha); ok {
+			entry, err := r.graph.entry(pos)
+			if err != nil {
+				// Corrupt commit-graph, parse the commit objects from now on
+				r.graph = nil
+				return r.commit(sha)
+			}
+			commit := &nativeCommit{}
+			commit.sha = sha
+			commit.tree = entry.tree
+			commit.parents = entry.parents
+			commit.refs = make([]Ref, 0)
+			commit.commitTime = entry.commitTime
+			commit.generation = entry.generation
+			r.commits[sha] = commit
+			return commit, nil
+		}
+	}
+	commit, err := r.readCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+	r.commits[sha] = commit
+	return commit, nil
+}
+
+// loadMetadata inflate the commit object to get the author and subject of a commit read from the commit-graph
+func (r *nativeRepo) loadMetadata(commit *nativeCommit) error {
+	if commit.loaded {
+		return nil
+	}
+	full, err := r.readCommit(commit.sha)
+	if err != nil {
+		return err
+	}
+	commit.authorName = full.authorName
+	commit.authorEmail = full.authorEmail
+	commit.timestamp = full.timestamp
+	commit.date = full.date
+	commit.subject = full.subject
+	commit.loaded = true
+	return nil
+}
+
+func (r *nativeRepo) readCommit(sha string) (*nativeCommit, error) {
+	objType, data, err := r.objects.read(sha)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %v", sha, err)
+	}
+	if objType != "commit" {
+		return nil, fmt.Errorf("%s is a %s, not a commit", sha, objType)
+	}
+	commit, err := parseCommit(sha, data)
+	if err != nil {
+		return nil, err
+	}
+	if r.shallow[sha] {
+		commit.parents = make([]string, 0)
+	}
+	return commit, nil
+}
+
+func parseCommit(sha string, data []byte) (*nativeCommit, error) {
+	commit := &nativeCommit{}
+	commit.sha = sha
+	commit.parents = make([]string, 0)
+	commit.refs = make([]Ref, 0)
+	content := string(data)
+	headers := content
+	message := ""
+	if end := strings.Index(content, "\n\n"); end >= 0 {
+		headers = content[:end]
+		message = content[end+2:]
+	}
+	for _, line := range strings.Split(headers, "\n") {
+		switch {
+		case strings.HasPrefix(line, "tree "):
+			commit.tree = strings.TrimPrefix(line, "tree ")
+		case strings.HasPrefix(line, "parent "):
+			commit.parents = append(commit.parents, strings.TrimPrefix(line, "parent "))
+		case strings.HasPrefix(line, "author "):
+			name, email, timestamp, tz, err := parseSignature(strings.TrimPrefix(line, "author "))
+			if err != nil {
+				return nil, fmt.Errorf("commit %s: %v", sha, err)
+			}
+			commit.authorName = name
+			commit.authorEmail = email
+			commit.timestamp = timestamp
+			commit.date = time.Unix(timestamp, 0).In(tz).Format("2006-01-02 15:04:05 -0700")
+		case strings.HasPrefix(line, "committer "):
+			_, _, timestamp, _, err := parseSignature(strings.TrimPrefix(line, "committer "))
+			if err != nil {
+				return nil, fmt.Errorf("commit %s: %v", sha, err)
+			}
+			commit.commitTime = timestamp
+		}
+	}
+	commit.subject = commitSubject(message)
+	commit.loaded = true
+	return commit, nil
+}
+
+// parseSignature parse "Name <email> 1420113600 +0100"
+func parseSignature(signature string) (name, email string, timestamp int64, tz *time.Location, err error) {
+	emailStart := strings.LastIndex(signature, "<")
+	emailEnd := strings.LastIndex(signature, ">")
+	if emailStart < 0 || emailEnd < emailStart {
+		err = fmt.Errorf("malformed signature %q", signature)
+		return
+	}
+	name = strings.TrimSpace(signature[:emailStart])
+	email = signature[emailStart+1 : emailEnd]
+	fields := strings.Fields(signature[emailEnd+1:])
+	tz = time.UTC
+	if len(fields) > 0 {
+		timestamp, _ = strconv.ParseInt(fields[0], 10, 64)
+	}
+	if len(fields) > 1 && len(fields[1]) == 5 {
+		hours, _ := strconv.Atoi(fields[1][1:3])
+		minutes, _ := strconv.Atoi(fields[1][3:5])
+		offset := hours*3600 + minutes*60
+		if fields[1][0] == '-' {
+			offset = -offset
+		}
+		tz = time.FixedZone("", offset)
+	}
+	return
+}
+
+// commitSubject first paragraph of the message joined on one line, like git %s
+func commitSubject(message string) string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(message, "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		if line == "" {
+			if len(lines) > 0 {
+				break
+			}
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, " ")
+}
+
+var revSuffixRegexp = regexp.MustCompile(`([~^])(\d*)$`)
+
+// resolveRevision get the commit a single revision points to (HEAD, master, v1.0, sha, master~2, HEAD^2)
+func (r *nativeRepo) resolveRevision(rev string) (string, error) {
+	if m := revSuffixRegexp.FindStringSubmatchIndex(rev); m != nil && m[0] > 0 {
+		base, err := r.resolveRevision(rev[:m[0]])
+		if err != nil {
+			return "", err
+		}
+		op := rev[m[2]:m[3]]
+		n := 1
+		if m[4] != m[5] {
+			n, _ = strconv.Atoi(rev[m[4]:m[5]])
+		}
+		if op == "~" {
+			for i := 0; i < n; i++ {
+				commit, err := r.commit(base)
+				if err != nil {
+					return "", err
+				}
+				if len(commit.parents) == 0 {
+					return "", fmt.Errorf("unknown revision %s", rev)
+				}
+				base = commit.parents[0]
+			}
+			return base, nil
+		}
+		if n == 0 {
+			return base, nil
+		}
+		commit, err := r.commit(base)
+		if err != nil {
+			return "", err
+		}
+		if n > len(commit.parents) {
+			return "", fmt.Errorf("unknown revision %s", rev)
+		}
+		return commit.parents[n-1], nil
+	}
+
+	for _, name := range []string{rev, "refs/" + rev, "refs/tags/" + rev, "refs/heads/" + rev, "refs/remotes/" + rev, "refs/remotes/" + rev + "/HEAD"} {
+		if sha, ok := r.resolveRef(name); ok {
+			return r.peel(sha)
+		}
+	}
+	if shortShaRegexp.MatchString(rev) {
+		found := make(map[string]bool)
+		r.objects.expand(rev, found)
+		if len(found) > 1 {
+			return "", fmt.Errorf("short object id %s is ambiguous", rev)
+		}
+		for sha := range found {
+			return r.peel(sha)
+		}
+	}
+	return "", fmt.Errorf("unknown revision %s", rev)
+}
+
+// refsTips commits pointed by the refs starting with prefix, refs that are not commits are ignored
+func (r *nativeRepo) refsTips(prefix string) []string {
+	tips := make([]string, 0)
+	for _, name := range r.refNames(prefix) {
+		sha, ok := r.resolveRef(name)
+		if !ok {
+			continue
+		}
+		if commit, err := r.peel(sha); err == nil {
+			tips = append(tips, commit)
+		}
+	}
+	return tips
+}
+
+// parseRevisions get the commits to start from and the commits to exclude
+func (r *nativeRepo) parseRevisions(opts RepoOptions) (include []string, exclude map[string]bool, err error) {
+	revisions := opts.Revisions
+	if len(revisions) == 0 {
+		revisions = []string{"--branches", "--remotes"}
+		if opts.Tags {
+			revisions = append(revisions, "--tags")
+		}
+	}
+	negatives := make([]string, 0)
+	symmetric := make([][2]string, 0)
+	for _, rev := range revisions {
+		switch {
+		case rev == "--all":
+			if head, err := r.resolveRevision("HEAD"); err == nil {
+				include = append(include, head)
+			}
+			include = append(include, r.refsTips("refs/")...)
+		case rev == "--branches":
+			include = append(include, r.refsTips("refs/heads/")...)
+		case rev == "--remotes":
+			include = append(include, r.refsTips("refs/remotes/")...)
+		case rev == "--tags":
+			include = append(include, r.refsTips("refs/tags/")...)
+		case strings.HasPrefix(rev, "-"):
+			return nil, nil, fmt.Errorf("unsupported option %s", rev)
+		case strings.Contains(rev, "..."):
+			parts := strings.SplitN(rev, "...", 2)
+			left, right, err := r.resolveRange(parts[0], parts[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, left, right)
+			symmetric = append(symmetric, [2]string{left, right})
+		case strings.Contains(rev, ".."):
+			parts := strings.SplitN(rev, "..", 2)
+			left, right, err := r.resolveRange(parts[0], parts[1])
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, right)
+			negatives = append(negatives, left)
+		case strings.HasPrefix(rev, "^"):
+			sha, err := r.resolveRevision(rev[1:])
+			if err != nil {
+				return nil, nil, err
+			}
+			negatives = append(negatives, sha)
+		default:
+			sha, err := r.resolveRevision(rev)
+			if err != nil {
+				return nil, nil, err
+			}
+			include = append(include, sha)
+		}
+	}
+
+	exclude = make(map[string]bool)
+	if len(negatives) > 0 {
+		// Exclude commits reachable from a negative revision
+		tips := append(append([]string{}, include...), negatives...)
+		flags := make([]uint8, len(tips))
+		for i := range tips {
+			flags[i] = 1
+			if i >= len(include) {
+				flags[i] = 2
+			}
+		}
+		if err = r.exclude(tips, flags, func(f uint8) bool { return f&2 != 0 }, exclude); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, pair := range symmetric {
+		// Symmetric difference, exclude commits reachable from both sides
+		both := func(f uint8) bool { return f == 3 }
+		if err = r.exclude(pair[:], []uint8{1, 2}, both, exclude); err != nil {
+			return nil, nil, err
+		}
+	}
+	return
+}
+
+// resolveRange resolve both sides of a range, an empty side means HEAD
+func (r *nativeRepo) resolveRange(left, right string) (string, string, error) {
+	if left == "" {
+		left = "HEAD"
+	}
+	if right == "" {
+		right = "HEAD"
+	}
+	leftSha, err := r.resolveRevision(left)
+	if err != nil {
+		return "", "", err
+	}
+	rightSha, err := r.resolveRevision(right)
+	if err != nil {
+		return "", "", err
+	}
+	return leftSha, rightSha, nil
+}
+
+// exclude paint the ancestors of the tips with their flags (a commit gets the flags of all its children),
+// and add to exclude the commits whose flags are excluded.
+// Ancestors of an excluded commit are not always added, the history walk never goes past an excluded commit.
+func (r *nativeRepo) exclude(tips []string, tipFlags []uint8, excluded func(uint8) bool, exclude map[string]bool) error {
+	flags, ok, err := r.paintByGeneration(tips, tipFlags, excluded)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		flags, err = r.paintAll(tips, tipFlags)
+		if err != nil {
+			return err
+		}
+	}
+	for sha, f := range flags {
+		if excluded(f) {
+			exclude[sha] = true
+		}
+	}
+	return nil
+}
+
+// paintAll paint the whole history reachable from the tips
+func (r *nativeRepo) paintAll(tips []string, tipFlags []uint8) (map[string]uint8, error) {
+	flags := make(map[string]uint8)
+	for i, tip := range tips {
+		stack := []string{tip}
+		if flags[tip]&tipFlags[i] == tipFlags[i] {
+			continue
+		}
+		flags[tip] |= tipFlags[i]
+		for len(stack) > 0 {
+			current := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			commit, err := r.commit(current)
+			if err != nil {
+				return nil, err
+			}
+			for _, parent := range commit.parents {
+				if flags[parent]&tipFlags[i] != tipFlags[i] {
+					flags[parent] |= tipFlags[i]
+					stack = append(stack, parent)
+				}
+			}
+		}
+	}
+	return flags, nil
+}
+
+// paintByGeneration paint the history using the generation numbers of the commit-graph.
+// Commits are processed by decreasing generation, so all the children of a commit are processed before it,
+// and the walk stops as soon as every commit left is stale. It returns false when a commit has no generation.
+func (r *nativeRepo) paintByGeneration(tips []string, tipFlags []uint8, stale func(uint8) bool) (map[string]uint8, bool, error) {
+	flags := make(map[string]uint8)
+	queue := &generationQueue{}
+	nbActive := 0
+	push := func(sha string, f uint8) (bool, error) {
+		previous, queued := flags[sha]
+		if queued && previous|f == previous {
+			return true, nil
+		}
+		flags[sha] = previous | f
+		if !queued {
+			commit, err := r.commit(sha)
+			if err != nil {
+				return false, err
+			}
+			if commit.generation == 0 {
+				return false, nil
+			}
+			heap.Push(queue, commit)
+			if !stale(flags[sha]) {
+				nbActive++
+			}
+		} else if !stale(previous) && stale(flags[sha]) {
+			nbActive--
+		}
+		return true, nil
+	}
+	for i, tip := range tips {
+		if ok, err := push(tip, tipFlags[i]); !ok || err != nil {
+			return nil, false, err
+		}
+	}
+	for queue.Len() > 0 && nbActive > 0 {
+		commit := heap.Pop(queue).(*nativeCommit)
+		f := flags[commit.sha]
+		if !stale(f) {
+			nbActive--
+		}
+		for _, parent := range commit.parents {
+			if ok, err := push(parent, f); !ok || err != nil {
+				return nil, false, err
+			}
+		}
+	}
+	return flags, true, nil
+}
+
+// generationQueue commits ordered by decreasing generation
+type generationQueue []*nativeCommit
+
+func (q generationQueue) Len() int            { return len(q) }
+func (q generationQueue) Less(i, j int) bool  { return q[i].generation > q[j].generation }
+func (q generationQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *generationQueue) Push(x interface{}) { *q = append(*q, x.(*nativeCommit)) }
+func (q *generationQueue) Pop() interface{} {
+	old := *q
+	commit := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return commit
+}
+
+// decorations refs pointing at each commit, HEAD first then by ref name
+func (r *nativeRepo) decorations(tags bool) map[string][]Ref {
+	decorations := make(map[string][]Ref)
+	add := func(name string) {
+		ref, ok := refFromName(name)
+		if !ok || (!tags && ref.Type == TagRef) {
+			return
+		}
+		sha, ok := r.resolveRef(name)
+		if !ok {
+			return
+		}
+		if sha, err := r.peel(sha); err == nil {
+			decorations[sha] = append(decorations[sha], ref)
+		}
+	}
+	add("HEAD")
+	headTarget := r.symRefs["HEAD"]
+	if headTarget != "" {
+		add(headTarget)
+	}
+	for _, name := range r.refNames("refs/") {
+		if name != headTarget {
+			add(name)
+		}
+	}
+	return decorations
+}
+
+// commitQueue commits ordered by commit date, most recent first, then by insertion order
+type commitQueue struct {
+	commits []*nativeCommit
+	seq     []int
+	nextSeq int
+}
+
+func (q *commitQueue) Len() int { return len(q.commits) }
+func (q *commitQueue) Less(i, j int) bool {
+	if q.commits[i].commitTime != q.commits[j].commitTime {
+		return q.commits[i].commitTime > q.commits[j].commitTime
+	}
+	return q.seq[i] < q.seq[j]
+}
+func (q *commitQueue) Swap(i, j int) {
+	q.commits[i], q.commits[j] = q.commits[j], q.commits[i]
+	q.seq[i], q.seq[j] = q.seq[j], q.seq[i]
+}
+func (q *commitQueue) Push(x interface{}) {
+	q.commits = append(q.commits, x.(*nativeCommit))
+	q.seq = append(q.seq, q.nextSeq)
+	q.nextSeq++
+}
+func (q *commitQueue) Pop() interface{} {
+	n := len(q.commits) - 1
+	commit := q.commits[n]
+	q.commits = q.commits[:n]
+	q.seq = q.seq[:n]
+	return commit
+}
+
+// log walk the history like git log --date-order does (by commit date, never a parent before its children)
+func (r *nativeRepo) log(opts RepoOptions) ([]repoCommit, error) {
+	include, exclude, err := r.parseRevisions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var since, until int64
+	if opts.Since != "" {
+		if since, err = parseNativeDate(opts.Since); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Until != "" {
+		if until, err = parseNativeDate(opts.Until); err != nil {
+			return nil, err
+		}
+	}
+	decorations := r.decorations(opts.Tags)
+
+	queue := &commitQueue{}
+	seen := make(map[string]bool)
+	push := func(sha string) error {
+		if seen[sha] || exclude[sha] {
+			return nil
+		}
+		seen[sha] = true
+		commit, err := r.commit(sha)
+		if err != nil {
+			return err
+		}
+		heap.Push(queue, commit)
+		return nil
+	}
+	for _, sha := range include {
+		if err := push(sha); err != nil {
+			return nil, err
+		}
+	}
+
+	walked := make([]*nativeCommit, 0)
+	old := make([]string, 0)
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(*nativeCommit)
+		// Like git, do not go further than commits older than --since
+		if opts.Since != "" && commit.commitTime < since {
+			old = append(old, commit.sha)
+			continue
+		}
+		for _, parent := range commit.parents {
+			if err := push(parent); err != nil {
+				return nil, err
+			}
+		}
+		walked = append(walked, commit)
+	}
+	// Like git --date-order, the ancestors of the commits older than --since are not shown either
+	stale := make(map[string]bool)
+	if len(old) > 0 {
+		tips := append(append([]string{}, include...), old...)
+		flags := make([]uint8, len(tips))
+		for i := range tips {
+			flags[i] = 1
+			if i >= len(include) {
+				flags[i] = 2
+			}
+		}
+		if err := r.exclude(tips, flags, func(f uint8) bool { return f&2 != 0 }, stale); err != nil {
+			return nil, err
+		}
+	}
+
+	commits := make([]repoCommit, 0)
+	for _, commit := range dateOrder(walked) {
+		if stale[commit.sha] || opts.Until != "" && commit.commitTime > until {
+			continue
+		}
+		if !opts.TopologyOnly {
+			if err := r.loadMetadata(commit); err != nil {
+				return nil, err
+			}
+		}
+		out := commit.repoCommit
+		if refs, ok := decorations[commit.sha]; ok {
+			out.refs = refs
+		}
+		commits = append(commits, out)
+		if opts.MaxCount > 0 && len(commits) >= opts.MaxCount {
+			break
+		}
+	}
+	return commits, nil
+}
+
+// dateOrder sort the walked commits by commit date, holding each commit until all its children are placed
+func dateOrder(walked []*nativeCommit) []*nativeCommit {
+	byID := make(map[string]*nativeCommit, len(walked))
+	for _, commit := range walked {
+		byID[commit.sha] = commit
+	}
+	children := make(map[string]int, len(walked))
+	for _, commit := range walked {
+		for _, parent := range commit.parents {
+			if _, ok := byID[parent]; ok {
+				children[parent]++
+			}
+		}
+	}
+	queue := &commitQueue{}
+	for _, commit := range walked {
+		if children[commit.sha] == 0 {
+			heap.Push(queue, commit)
+		}
+	}
+	sorted := make([]*nativeCommit, 0, len(walked))
+	for queue.Len() > 0 {
+		commit := heap.Pop(queue).(*nativeCommit)
+		sorted = append(sorted, commit)
+		for _, parent := range commit.parents {
+			if _, ok := byID[parent]; !ok {
+				continue
+			}
+			if children[parent]--; children[parent] == 0 {
+				heap.Push(queue, byID[parent])
+			}
+		}
+	}
+	return sorted
+}
+
+// parseNativeDate parse the --since/--until dates supported by the native reader
+func parseNativeDate(date string) (int64, error) {
+	if strings.HasPrefix(date, "@") {
+		return strconv.ParseInt(date[1:], 10, 64)
+	}
+	layouts := []string{time.RFC3339, "2006-01-02 15:04:05 -0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, date, time.Local); err == nil {
+			return t.Unix(), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported date %q", date)
+}
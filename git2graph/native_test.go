@@ -0,0 +1,264 @@
+package git2graph
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newNativeTestRepo extends the test repository with a merge, an annotated tag and a remote branch
+//
+//	F master (merge)
+//	|\
+//	| E feature, origin/feature
+//	| D
+//	C |
+//	B | v1.0, v1.1
+//	|/
+//	A
+func newNativeTestRepo(t *testing.T) string {
+	dir := newTestRepo(t)
+	date := "2015-01-06T12:00:00+00:00"
+	env := []string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}
+	runGit(t, dir, env, "merge", "-q", "--no-ff", "-m", "Merge feature\n\nwith a body", "feature")
+	runGit(t, dir, env, "tag", "-a", "-m", "annotated", "v1.1", "v1.0")
+	runGit(t, dir, nil, "update-ref", "refs/remotes/origin/feature", "feature")
+	return dir
+}
+
+func sortRefs(nodes []map[string]interface{}) {
+	for _, node := range nodes {
+		refs := node["refs"].([]Ref)
+		sort.Slice(refs, func(i, j int) bool {
+			return refs[i].Type < refs[j].Type || refs[i].Type == refs[j].Type && refs[i].Name < refs[j].Name
+		})
+	}
+}
+
+func compareReaders(t *testing.T, dir string) {
+	inputs := []RepoOptions{
+		{},
+		{Tags: true},
+		{SeqIds: true},
+		{Revisions: []string{"--all"}},
+		{Revisions: []string{"master..feature"}},
+		{Revisions: []string{"feature...master~1"}},
+		{Revisions: []string{"master", "^feature"}},
+		{Revisions: []string{"v1.1"}},
+		{Revisions: []string{"HEAD^2~1"}},
+		{MaxCount: 3},
+		{Since: "2015-01-02T00:00:00+00:00", Until: "2015-01-04T00:00:00+00:00"},
+	}
+	for _, opts := range inputs {
+		opts.Path = dir
+		expected, err := GetInputNodesFromRepoWithOptions(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Native = true
+		actual, err := GetInputNodesFromRepoWithOptions(opts)
+		if err != nil {
+			t.Errorf("Options: %+v, %v", opts, err)
+			continue
+		}
+		sortRefs(expected)
+		sortRefs(actual)
+		if !reflect.DeepEqual(expected, actual) {
+			t.Errorf("Options: %+v\nExpected: %v\nActual:   %v", opts, expected, actual)
+		}
+	}
+}
+
+func TestNativeReaderLooseObjects(t *testing.T) {
+	dir := newNativeTestRepo(t)
+	compareReaders(t, dir)
+}
+
+func TestNativeReaderPackfiles(t *testing.T) {
+	dir := newNativeTestRepo(t)
+	runGit(t, dir, nil, "gc", "-q", "--aggressive")
+	runGit(t, dir, nil, "pack-refs", "--all")
+	compareReaders(t, dir)
+}
+
+func TestNativeReaderCommitGraph(t *testing.T) {
+	dir := newNativeTestRepo(t)
+	runGit(t, dir, nil, "commit-graph", "write", "--reachable")
+	compareReaders(t, dir)
+
+	// Split commit-graph chain
+	runGit(t, dir, nil, "checkout", "-q", "-b", "next")
+	runGit(t, dir, nil, "commit", "-q", "--allow-empty", "-m", "G")
+	runGit(t, dir, nil, "checkout", "-q", "master")
+	runGit(t, dir, nil, "commit-graph", "write", "--reachable", "--split=no-merge")
+	compareReaders(t, dir)
+
+	repo, err := openNativeRepo(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.close()
+	if repo.graph == nil || len(repo.graph.layers) != 2 {
+		t.Fatalf("Expected a commit-graph chain of 2 layers")
+	}
+	head, _ := repo.resolveRevision("HEAD")
+	feature, _ := repo.resolveRevision("feature")
+	commit, _ := repo.commit(head)
+	if commit.loaded || commit.generation != 4 {
+		t.Errorf("Expected an unloaded commit of generation 4, Actual: %v", commit)
+	}
+	if _, ok, err := repo.paintByGeneration([]string{head, feature}, []uint8{1, 2}, func(f uint8) bool { return f&2 != 0 }); !ok || err != nil {
+		t.Errorf("Expected the generation numbers to be used, %v", err)
+	}
+}
+
+func TestNativeReaderCorruptCommitGraph(t *testing.T) {
+	dir := newNativeTestRepo(t)
+	runGit(t, dir, nil, "commit-graph", "write", "--reachable")
+	// git ignores the commit-graph, the native reader falls back to the commit objects
+	runGit(t, dir, nil, "config", "core.commitGraph", "false")
+	path := filepath.Join(dir, ".git", "objects", "info", "commit-graph")
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(content []byte) {
+		os.Remove(path)
+		if err := ioutil.WriteFile(path, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// First parents out of the commit-graph
+	for i := 0; i < int(content[6]); i++ {
+		chunk := content[8+i*12:]
+		if string(chunk[:4]) == "CDAT" {
+			start, end := binary.BigEndian.Uint64(chunk[4:12]), binary.BigEndian.Uint64(chunk[16:24])
+			for pos := start; pos < end; pos += 36 {
+				binary.BigEndian.PutUint32(content[pos+20:], 0xffffff)
+			}
+		}
+	}
+	write(content)
+	compareReaders(t, dir)
+
+	write(content[:100])
+	compareReaders(t, dir)
+}
+
+func TestNativeReaderSkewedDates(t *testing.T) {
+	dir := newNativeTestRepo(t)
+	// G and H are dated before their parent C
+	runGit(t, dir, nil, "checkout", "-q", "-b", "skewed", "master~1")
+	for _, subject := range []string{"G", "H"} {
+		date := "2014-12-31T12:00:00+00:00"
+		runGit(t, dir, []string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}, "commit", "-q", "--allow-empty", "-m", subject)
+	}
+	runGit(t, dir, nil, "checkout", "-q", "master")
+	compareReaders(t, dir)
+}
+
+func TestNativeReaderErrors(t *testing.T) {
+	dir := newNativeTestRepo(t)
+	if _, err := GetInputNodesFromRepoWithOptions(RepoOptions{Path: dir, Native: true, Revisions: []string{"unknown"}}); err == nil {
+		t.Error("Expected an error for an unknown revision")
+	}
+	if _, err := GetInputNodesFromRepoWithOptions(RepoOptions{Path: t.TempDir(), Native: true}); err == nil {
+		t.Error("Expected an error outside of a repository")
+	}
+}
+
+func TestApplyDelta(t *testing.T) {
+	base := []byte("hello world")
+	// src size 11, dst size 16, copy base[0:6], insert "there ", copy base[6:10]
+	delta := []byte{11, 16, 0x90, 6, 6, 't', 'h', 'e', 'r', 'e', ' ', 0x91, 6, 4}
+	out, err := applyDelta(base, delta)
+	if err != nil || string(out) != "hello there worl" {
+		t.Errorf("Expected: %q, Actual: %q, %v", "hello there worl", out, err)
+	}
+	if _, err := applyDelta(base, []byte{12, 16}); err == nil {
+		t.Error("Expected an error when the base size does not match")
+	}
+}
+
+func TestCommitSubject(t *testing.T) {
+	inputs := map[string]string{
+		"Subject\n\nBody":               "Subject",
+		"\n\nSubject  \non two lines\n": "Subject on two lines",
+		"":                              "",
+	}
+	for message, expected := range inputs {
+		if actual := commitSubject(message); actual != expected {
+			t.Errorf("Expected: %q, Actual: %q", expected, actual)
+		}
+	}
+}
+
+// newBenchRepo create a repository of n commits, with a merge from a side branch every 10 commits
+func newBenchRepo(b *testing.B, n int) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		b.Skip("git not found in PATH")
+	}
+	dir := b.TempDir()
+	cmd := exec.Command("git", "init", "-q", dir)
+	if err := cmd.Run(); err != nil {
+		b.Fatal(err)
+	}
+	var stream bytes.Buffer
+	for i := 1; i <= n; i++ {
+		branch := "refs/heads/master"
+		if i%10 == 5 {
+			branch = "refs/heads/side"
+		}
+		fmt.Fprintf(&stream, "commit %s\nmark :%d\n", branch, i)
+		fmt.Fprintf(&stream, "author Alain Gilbert <alain@example.com> %d +0000\n", 1420000000+i*60)
+		fmt.Fprintf(&stream, "committer Alain Gilbert <alain@example.com> %d +0000\n", 1420000000+i*60)
+		fmt.Fprintf(&stream, "data <<EOF\nCommit %d\nEOF\n", i)
+		if i > 1 {
+			fmt.Fprintf(&stream, "from :%d\n", i-1)
+		}
+		if i%10 == 6 {
+			fmt.Fprintf(&stream, "merge :%d\n", i-1)
+		}
+		fmt.Fprintf(&stream, "M 644 inline file.txt\ndata <<EOF\n%d\nEOF\n\n", i)
+	}
+	cmd = exec.Command("git", "fast-import", "--quiet")
+	cmd.Dir = dir
+	cmd.Stdin = &stream
+	if out, err := cmd.CombinedOutput(); err != nil {
+		b.Fatalf("git fast-import: %v, %s", err, out)
+	}
+	return dir
+}
+
+func BenchmarkReaders(b *testing.B) {
+	dir := newBenchRepo(b, 20000)
+	bench := func(name string, opts RepoOptions) {
+		opts.Path = dir
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := GetInputNodesFromRepoWithOptions(opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+	bench("Exec", RepoOptions{})
+	bench("Native", RepoOptions{Native: true})
+	bench("NativeTopologyOnly", RepoOptions{Native: true, TopologyOnly: true})
+	cmd := exec.Command("git", "commit-graph", "write", "--reachable")
+	cmd.Dir = dir
+	if err := cmd.Run(); err != nil {
+		b.Fatal(err)
+	}
+	// The default options still inflate every commit for its metadata, only the topology is read from the commit-graph
+	bench("NativeCommitGraph", RepoOptions{Native: true})
+	bench("NativeCommitGraphTopologyOnly", RepoOptions{Native: true, TopologyOnly: true})
+}
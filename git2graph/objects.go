@@ -0,0 +1,434 @@
+package git2graph
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Pack object types
+const (
+	objCommit   = 1
+	objTree     = 2
+	objBlob     = 3
+	objTag      = 4
+	objOfsDelta = 6
+	objRefDelta = 7
+)
+
+var objTypeNames = map[int]string{objCommit: "commit", objTree: "tree", objBlob: "blob", objTag: "tag"}
+
+var errObjectNotFound = errors.New("object not found")
+
+// objectStore read git objects from the loose objects and the packfiles of an objects directory
+type objectStore struct {
+	objectsDir string
+	packs      []*packFile
+	alternates []*objectStore
+}
+
+func openObjectStore(objectsDir string) (*objectStore, error) {
+	return openObjectStoreDepth(objectsDir, 0)
+}
+
+func openObjectStoreDepth(objectsDir string, depth int) (*objectStore, error) {
+	s := &objectStore{objectsDir: objectsDir}
+	idxPaths, err := filepath.Glob(filepath.Join(objectsDir, "pack", "*.idx"))
+	if err != nil {
+		return nil, err
+	}
+	for _, idxPath := range idxPaths {
+		pack, err := openPackFile(idxPath)
+		if err != nil {
+			s.close()
+			return nil, err
+		}
+		s.packs = append(s.packs, pack)
+	}
+	// Git follows alternates up to 5 levels deep
+	if alternates, err := ioutil.ReadFile(filepath.Join(objectsDir, "info", "alternates")); err == nil && depth < 5 {
+		for _, line := range strings.Split(string(alternates), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !filepath.IsAbs(line) {
+				line = filepath.Join(objectsDir, line)
+			}
+			alternate, err := openObjectStoreDepth(line, depth+1)
+			if err != nil {
+				s.close()
+				return nil, err
+			}
+			s.alternates = append(s.alternates, alternate)
+		}
+	}
+	return s, nil
+}
+
+func (s *objectStore) close() {
+	for _, pack := range s.packs {
+		pack.file.Close()
+	}
+	for _, alternate := range s.alternates {
+		alternate.close()
+	}
+}
+
+// read get the type and content of an object
+func (s *objectStore) read(sha string) (string, []byte, error) {
+	objType, data, err := s.readLoose(sha)
+	if err != errObjectNotFound {
+		return objType, data, err
+	}
+	rawSha, err := hex.DecodeString(sha)
+	if err != nil || len(rawSha) != 20 {
+		return "", nil, fmt.Errorf("invalid object id %q", sha)
+	}
+	for _, pack := range s.packs {
+		if offset, ok := pack.find(rawSha); ok {
+			typ, data, err := pack.readAt(offset, s)
+			if err != nil {
+				return "", nil, fmt.Errorf("object %s: %v", sha, err)
+			}
+			return objTypeNames[typ], data, nil
+		}
+	}
+	for _, alternate := range s.alternates {
+		objType, data, err := alternate.read(sha)
+		if err != errObjectNotFound {
+			return objType, data, err
+		}
+	}
+	return "", nil, errObjectNotFound
+}
+
+func (s *objectStore) readLoose(sha string) (string, []byte, error) {
+	if len(sha) < 3 {
+		return "", nil, errObjectNotFound
+	}
+	f, err := os.Open(filepath.Join(s.objectsDir, sha[:2], sha[2:]))
+	if err != nil {
+		return "", nil, errObjectNotFound
+	}
+	defer f.Close()
+	zr, err := zlib.NewReader(f)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %v", sha, err)
+	}
+	defer zr.Close()
+	content, err := ioutil.ReadAll(zr)
+	if err != nil {
+		return "", nil, fmt.Errorf("object %s: %v", sha, err)
+	}
+	// Header is "<type> <size>\0"
+	nul := bytes.IndexByte(content, 0)
+	if nul < 0 {
+		return "", nil, fmt.Errorf("object %s: malformed header", sha)
+	}
+	header := strings.SplitN(string(content[:nul]), " ", 2)
+	if len(header) != 2 {
+		return "", nil, fmt.Errorf("object %s: malformed header", sha)
+	}
+	if size, err := strconv.Atoi(header[1]); err != nil || size != len(content)-nul-1 {
+		return "", nil, fmt.Errorf("object %s: bad size", sha)
+	}
+	return header[0], content[nul+1:], nil
+}
+
+// expand find the full sha of an abbreviated object id
+func (s *objectStore) expand(prefix string, found map[string]bool) {
+	if len(prefix) >= 2 {
+		if entries, err := ioutil.ReadDir(filepath.Join(s.objectsDir, prefix[:2])); err == nil {
+			for _, entry := range entries {
+				sha := prefix[:2] + entry.Name()
+				if strings.HasPrefix(sha, prefix) && len(sha) == 40 {
+					found[sha] = true
+				}
+			}
+		}
+	}
+	for _, pack := range s.packs {
+		pack.expand(prefix, found)
+	}
+	for _, alternate := range s.alternates {
+		alternate.expand(prefix, found)
+	}
+}
+
+// packFile packfile and its version 2 index
+type packFile struct {
+	file         *os.File
+	fanout       [256]uint32
+	shas         []byte // 20 bytes per object, sorted
+	offsets      []byte // 4 bytes per object
+	largeOffsets []byte // 8 bytes per large offset
+	cache        map[int64]packObject
+	reader       *bufio.Reader // Reused between reads, allocating a zlib reader is expensive
+	zlibReader   io.ReadCloser
+}
+
+type packObject struct {
+	typ  int
+	data []byte
+}
+
+const packCacheSize = 1024
+
+func openPackFile(idxPath string) (*packFile, error) {
+	idx, err := ioutil.ReadFile(idxPath)
+	if err != nil {
+		return nil, err
+	}
+	if len(idx) < 8+256*4 || !bytes.Equal(idx[:4], []byte{0xff, 't', 'O', 'c'}) || binary.BigEndian.Uint32(idx[4:8]) != 2 {
+		return nil, fmt.Errorf("%s: unsupported pack index version", idxPath)
+	}
+	p := &packFile{cache: make(map[int64]packObject)}
+	for i := 0; i < 256; i++ {
+		p.fanout[i] = binary.BigEndian.Uint32(idx[8+i*4:])
+	}
+	nb := int(p.fanout[255])
+	pos := 8 + 256*4
+	if len(idx) < pos+nb*(20+4+4) {
+		return nil, fmt.Errorf("%s: truncated pack index", idxPath)
+	}
+	p.shas = idx[pos : pos+nb*20]
+	pos += nb * 20
+	pos += nb * 4 // crc32
+	p.offsets = idx[pos : pos+nb*4]
+	pos += nb * 4
+	p.largeOffsets = idx[pos:]
+	p.file, err = os.Open(strings.TrimSuffix(idxPath, ".idx") + ".pack")
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *packFile) nbObjects() int {
+	return int(p.fanout[255])
+}
+
+func (p *packFile) sha(i int) []byte {
+	return p.shas[i*20 : i*20+20]
+}
+
+// find the offset of an object in the packfile
+func (p *packFile) find(sha []byte) (int64, bool) {
+	lo := 0
+	if sha[0] > 0 {
+		lo = int(p.fanout[sha[0]-1])
+	}
+	hi := int(p.fanout[sha[0]])
+	i := lo + sort.Search(hi-lo, func(i int) bool {
+		return bytes.Compare(p.sha(lo+i), sha) >= 0
+	})
+	if i >= hi || !bytes.Equal(p.sha(i), sha) {
+		return 0, false
+	}
+	return p.offset(i), true
+}
+
+func (p *packFile) offset(i int) int64 {
+	offset := binary.BigEndian.Uint32(p.offsets[i*4:])
+	if offset&0x80000000 != 0 {
+		largeIdx := int(offset & 0x7fffffff)
+		return int64(binary.BigEndian.Uint64(p.largeOffsets[largeIdx*8:]))
+	}
+	return int64(offset)
+}
+
+func (p *packFile) expand(prefix string, found map[string]bool) {
+	lo, hi := 0, p.nbObjects()
+	if first, err := hex.DecodeString(prefix[:2]); err == nil {
+		hi = int(p.fanout[first[0]])
+		if first[0] > 0 {
+			lo = int(p.fanout[first[0]-1])
+		}
+	}
+	for i := lo; i < hi; i++ {
+		sha := hex.EncodeToString(p.sha(i))
+		if strings.HasPrefix(sha, prefix) {
+			found[sha] = true
+		}
+	}
+}
+
+// readAt read the object at offset, resolving deltas
+func (p *packFile) readAt(offset int64, s *objectStore) (int, []byte, error) {
+	if obj, ok := p.cache[offset]; ok {
+		return obj.typ, obj.data, nil
+	}
+	section := io.NewSectionReader(p.file, offset, 1<<62)
+	if p.reader == nil {
+		p.reader = bufio.NewReaderSize(section, 512)
+	} else {
+		p.reader.Reset(section)
+	}
+	r := p.reader
+	c, err := r.ReadByte()
+	if err != nil {
+		return 0, nil, err
+	}
+	typ := int(c>>4) & 7
+	size := int64(c & 0x0f)
+	shift := uint(4)
+	for c&0x80 != 0 {
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		size |= int64(c&0x7f) << shift
+		shift += 7
+	}
+
+	var baseOffset int64
+	var baseSha []byte
+	switch typ {
+	case objOfsDelta:
+		if c, err = r.ReadByte(); err != nil {
+			return 0, nil, err
+		}
+		rel := int64(c & 0x7f)
+		for c&0x80 != 0 {
+			if c, err = r.ReadByte(); err != nil {
+				return 0, nil, err
+			}
+			rel = ((rel + 1) << 7) | int64(c&0x7f)
+		}
+		baseOffset = offset - rel
+	case objRefDelta:
+		baseSha = make([]byte, 20)
+		if _, err = io.ReadFull(r, baseSha); err != nil {
+			return 0, nil, err
+		}
+	case objCommit, objTree, objBlob, objTag:
+	default:
+		return 0, nil, fmt.Errorf("unknown object type %d at offset %d", typ, offset)
+	}
+
+	if p.zlibReader == nil {
+		p.zlibReader, err = zlib.NewReader(r)
+	} else {
+		err = p.zlibReader.(zlib.Resetter).Reset(r, nil)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	data := make([]byte, size)
+	if _, err = io.ReadFull(p.zlibReader, data); err != nil {
+		return 0, nil, err
+	}
+
+	if typ == objOfsDelta || typ == objRefDelta {
+		var base []byte
+		if typ == objOfsDelta {
+			typ, base, err = p.readAt(baseOffset, s)
+		} else {
+			var objType string
+			objType, base, err = s.read(hex.EncodeToString(baseSha))
+			typ = objTypeFromName(objType)
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		if data, err = applyDelta(base, data); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if len(p.cache) >= packCacheSize {
+		p.cache = make(map[int64]packObject)
+	}
+	p.cache[offset] = packObject{typ, data}
+	return typ, data, nil
+}
+
+func objTypeFromName(name string) int {
+	for typ, typName := range objTypeNames {
+		if typName == name {
+			return typ
+		}
+	}
+	return 0
+}
+
+// applyDelta rebuild an object from its base and a delta
+func applyDelta(base, delta []byte) ([]byte, error) {
+	errCorrupt := errors.New("corrupt delta")
+	readSize := func() (int, bool) {
+		size, shift := 0, uint(0)
+		for len(delta) > 0 {
+			c := delta[0]
+			delta = delta[1:]
+			size |= int(c&0x7f) << shift
+			shift += 7
+			if c&0x80 == 0 {
+				return size, true
+			}
+		}
+		return 0, false
+	}
+	srcSize, ok := readSize()
+	if !ok || srcSize != len(base) {
+		return nil, errCorrupt
+	}
+	dstSize, ok := readSize()
+	if !ok {
+		return nil, errCorrupt
+	}
+	out := make([]byte, 0, dstSize)
+	for len(delta) > 0 {
+		op := delta[0]
+		delta = delta[1:]
+		switch {
+		case op&0x80 != 0:
+			// Copy from base, the bits of op tell which offset and size bytes are present
+			offset, size := 0, 0
+			for i := uint(0); i < 7; i++ {
+				if op&(1<<i) == 0 {
+					continue
+				}
+				if len(delta) == 0 {
+					return nil, errCorrupt
+				}
+				if i < 4 {
+					offset |= int(delta[0]) << (8 * i)
+				} else {
+					size |= int(delta[0]) << (8 * (i - 4))
+				}
+				delta = delta[1:]
+			}
+			if size == 0 {
+				size = 0x10000
+			}
+			if offset+size > len(base) {
+				return nil, errCorrupt
+			}
+			out = append(out, base[offset:offset+size]...)
+		case op != 0:
+			// Insert the next op bytes
+			if int(op) > len(delta) {
+				return nil, errCorrupt
+			}
+			out = append(out, delta[:op]...)
+			delta = delta[op:]
+		default:
+			return nil, errCorrupt
+		}
+	}
+	if len(out) != dstSize {
+		return nil, errCorrupt
+	}
+	return out, nil
+}
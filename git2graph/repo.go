@@ -0,0 +1,221 @@
+package git2graph
+
+import (
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+func deleteEmpty(s []string) []string {
+	r := make([]string, 0)
+	for _, str := range s {
+		if str != "" {
+			r = append(r, str)
+		}
+	}
+	return r
+}
+
+const startOfCommit = "@@@@@@@@@@"
+
+// RefType type of a git reference
+type RefType string
+
+// Ref types
+const (
+	LocalBranchRef  RefType = "branch" // refs/heads/*
+	RemoteBranchRef RefType = "remote" // refs/remotes/*
+	TagRef          RefType = "tag"    // refs/tags/*
+	HeadRef         RefType = "head"   // HEAD
+)
+
+// Ref git reference pointing at a commit
+type Ref struct {
+	Name string  `json:"name"`
+	Type RefType `json:"type"`
+}
+
+// RepoOptions options used to read a repository
+type RepoOptions struct {
+	SeqIds       bool     // Use sequential ids instead of sha
+	Tags         bool     // Include tags
+	Path         string   // Repository path, defaults to the current directory
+	Revisions    []string // Revision specs (main..feature, --all, v1.0...v2.0), defaults to all branches and remotes
+	MaxCount     int      // Maximum number of commits, 0 means no limit
+	Since        string   // Only commits more recent than this date
+	Until        string   // Only commits older than this date
+	Native       bool     // Read the .git directory directly instead of running git
+	TopologyOnly bool     // Only keep ids, parents and refs (the native reader then only reads the commit-graph when present)
+}
+
+// GetInputNodesFromRepo TODO
+func GetInputNodesFromRepo(seqIds bool) (nodes []map[string]interface{}, err error) {
+	return GetInputNodesFromRepoWithOptions(RepoOptions{SeqIds: seqIds})
+}
+
+// GetInputNodesFromRepoWithOptions Get nodes from a repository
+func GetInputNodesFromRepoWithOptions(opts RepoOptions) (nodes []map[string]interface{}, err error) {
+	if err = checkRevisions(opts.Revisions); err != nil {
+		return
+	}
+	if opts.Native {
+		return getInputNodesFromGitDir(opts)
+	}
+	outBytes, err := exec.Command("git", gitLogArgs(opts)...).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			err = fmt.Errorf("git log: %s", strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return
+	}
+	nodes = parseGitLog(string(outBytes), opts)
+	return
+}
+
+// revisionOptions options accepted as revisions, they select refs
+var revisionOptions = map[string]bool{"--all": true, "--branches": true, "--remotes": true, "--tags": true}
+
+// checkRevisions reject the revisions that are options of git log (eg: --output=<file>), except revisionOptions
+func checkRevisions(revisions []string) error {
+	for _, rev := range revisions {
+		if strings.HasPrefix(rev, "-") && !revisionOptions[rev] {
+			return fmt.Errorf("unsupported option %s", rev)
+		}
+	}
+	return nil
+}
+
+func gitLogArgs(opts RepoOptions) []string {
+	args := make([]string, 0)
+	if opts.Path != "" {
+		args = append(args, "-C", opts.Path)
+	}
+	args = append(args, "log", "--pretty=tformat:"+startOfCommit+"%n%H%n%aN%n%aE%n%at%n%ai%n%P%n%T%n%s%n%D", "--decorate=full", "--date=local")
+	// Never a parent before its children, even when the commit dates are skewed
+	args = append(args, "--date-order")
+	if opts.MaxCount > 0 {
+		args = append(args, "--max-count="+strconv.Itoa(opts.MaxCount))
+	}
+	if opts.Since != "" {
+		args = append(args, "--since="+opts.Since)
+	}
+	if opts.Until != "" {
+		args = append(args, "--until="+opts.Until)
+	}
+	if len(opts.Revisions) > 0 {
+		args = append(args, opts.Revisions...)
+	} else {
+		args = append(args, "--branches", "--remotes")
+		if opts.Tags {
+			args = append(args, "--tags")
+		}
+	}
+	// Revisions must not be mistaken for paths
+	return append(args, "--")
+}
+
+// repoCommit commit read from a repository, either from git log or by the native reader
+type repoCommit struct {
+	sha         string
+	parents     []string
+	authorName  string
+	authorEmail string
+	timestamp   int64
+	date        string
+	tree        string
+	subject     string
+	refs        []Ref
+}
+
+// parseGitLog parse the output of git log, each commit is made of 10 lines:
+// separator, sha, author name, author email, timestamp, iso date, parents, tree, subject, refs
+func parseGitLog(outString string, opts RepoOptions) []map[string]interface{} {
+	lines := strings.Split(outString, "\n")
+	commits := make([]repoCommit, 0)
+	i := 0
+	for i+9 < len(lines) && lines[i] == startOfCommit {
+		i++
+		commit := repoCommit{}
+		commit.sha = lines[i]
+		commit.authorName = lines[i+1]
+		commit.authorEmail = lines[i+2]
+		commit.timestamp, _ = strconv.ParseInt(lines[i+3], 10, 64)
+		commit.date = lines[i+4]
+		commit.parents = deleteEmpty(strings.Split(lines[i+5], " "))
+		commit.tree = lines[i+6]
+		commit.subject = lines[i+7]
+		commit.refs = parseDecorations(lines[i+8], opts.Tags)
+		i += 9
+		commits = append(commits, commit)
+	}
+	return commitsToNodes(commits, opts)
+}
+
+func commitsToNodes(commits []repoCommit, opts RepoOptions) (nodes []map[string]interface{}) {
+	ids := make(map[string]string)
+	for i, commit := range commits {
+		ids[commit.sha] = commit.sha
+		if opts.SeqIds {
+			ids[commit.sha] = strconv.Itoa(i)
+		}
+	}
+	for _, commit := range commits {
+		node := map[string]interface{}{}
+		node["id"] = ids[commit.sha]
+		if opts.SeqIds {
+			node["sha"] = commit.sha
+		}
+		// Parents that were not read (outside of the revisions, --max-count, --since) are dropped
+		parents := make([]string, 0, len(commit.parents))
+		for _, parentSha := range commit.parents {
+			if id, ok := ids[parentSha]; ok {
+				parents = append(parents, id)
+			}
+		}
+		node["parents"] = parents
+		node["refs"] = commit.refs
+		if !opts.TopologyOnly {
+			node["author_name"] = commit.authorName
+			node["author_email"] = commit.authorEmail
+			node["timestamp"] = commit.timestamp
+			node["date"] = commit.date
+			node["tree"] = commit.tree
+			node["subject"] = commit.subject
+		}
+		nodes = append(nodes, node)
+	}
+	return
+}
+
+// parseDecorations parse the full decorations of a commit (%D with --decorate=full)
+// eg: "HEAD -> refs/heads/master, tag: refs/tags/v1.0, refs/remotes/origin/master"
+func parseDecorations(decorations string, tags bool) []Ref {
+	refs := make([]Ref, 0)
+	for _, decoration := range deleteEmpty(strings.Split(decorations, ", ")) {
+		if strings.HasPrefix(decoration, "HEAD -> ") {
+			refs = append(refs, Ref{"HEAD", HeadRef})
+			decoration = strings.TrimPrefix(decoration, "HEAD -> ")
+		}
+		decoration = strings.TrimPrefix(decoration, "tag: ")
+		if ref, ok := refFromName(decoration); ok && (tags || ref.Type != TagRef) {
+			refs = append(refs, ref)
+		}
+	}
+	return refs
+}
+
+// refFromName get the Ref of a full reference name (HEAD, refs/heads/master, ...)
+func refFromName(name string) (Ref, bool) {
+	switch {
+	case name == "HEAD":
+		return Ref{"HEAD", HeadRef}, true
+	case strings.HasPrefix(name, "refs/heads/"):
+		return Ref{strings.TrimPrefix(name, "refs/heads/"), LocalBranchRef}, true
+	case strings.HasPrefix(name, "refs/remotes/"):
+		return Ref{strings.TrimPrefix(name, "refs/remotes/"), RemoteBranchRef}, true
+	case strings.HasPrefix(name, "refs/tags/"):
+		return Ref{strings.TrimPrefix(name, "refs/tags/"), TagRef}, true
+	}
+	return Ref{}, false
+}
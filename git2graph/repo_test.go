@@ -0,0 +1,204 @@
+package git2graph
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func runGit(t *testing.T, dir string, env []string, args ...string) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), env...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v, %s", args, err, out)
+	}
+}
+
+// newTestRepo create a repository in a temp directory:
+//
+//	E feature
+//	D
+//	| C master
+//	| B v1.0
+//	|/
+//	A
+//
+// Commit i is dated 2015-01-0i
+func newTestRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found in PATH")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, nil, "init", "-q")
+	runGit(t, dir, nil, "config", "user.name", "Alain Gilbert")
+	runGit(t, dir, nil, "config", "user.email", "alain@example.com")
+	runGit(t, dir, nil, "config", "commit.gpgsign", "false")
+	runGit(t, dir, nil, "checkout", "-q", "-b", "master")
+	commit := func(day int, subject string) {
+		date := fmt.Sprintf("2015-01-0%dT12:00:00+00:00", day)
+		runGit(t, dir, []string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}, "commit", "-q", "--allow-empty", "-m", subject)
+	}
+	commit(1, "A")
+	runGit(t, dir, nil, "branch", "feature")
+	commit(2, "B")
+	runGit(t, dir, nil, "tag", "v1.0")
+	commit(3, "C")
+	runGit(t, dir, nil, "checkout", "-q", "feature")
+	commit(4, "D")
+	commit(5, "E")
+	runGit(t, dir, nil, "checkout", "-q", "master")
+	return dir
+}
+
+func subjects(nodes []map[string]interface{}) (out []string) {
+	for _, node := range nodes {
+		out = append(out, node["subject"].(string))
+	}
+	return
+}
+
+func TestParseGitLog(t *testing.T) {
+	gitLog := "@@@@@@@@@@\n" +
+		"bbbb\nAlain Gilbert\nalain@example.com\n1450000000\n2015-12-13 09:46:40 +0000\naaaa\ntree2\nSecond commit\n" +
+		"HEAD -> refs/heads/master, tag: refs/tags/v1.0, refs/remotes/origin/master\n" +
+		"@@@@@@@@@@\n" +
+		"aaaa\nAlain Gilbert\nalain@example.com\n1440000000\n2015-08-19 16:00:00 +0000\n\ntree1\nInitial commit\n\n"
+
+	nodes := parseGitLog(gitLog, RepoOptions{Tags: true})
+	if len(nodes) != 2 {
+		t.Fatalf("Expected 2 nodes, Actual: %d", len(nodes))
+	}
+	expected := map[string]interface{}{
+		"id":           "bbbb",
+		"parents":      []string{"aaaa"},
+		"author_name":  "Alain Gilbert",
+		"author_email": "alain@example.com",
+		"timestamp":    int64(1450000000),
+		"date":         "2015-12-13 09:46:40 +0000",
+		"tree":         "tree2",
+		"subject":      "Second commit",
+		"refs": []Ref{
+			{"HEAD", HeadRef},
+			{"master", LocalBranchRef},
+			{"v1.0", TagRef},
+			{"origin/master", RemoteBranchRef},
+		},
+	}
+	if !reflect.DeepEqual(nodes[0], expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, nodes[0])
+	}
+	if len(nodes[1]["parents"].([]string)) != 0 || len(nodes[1]["refs"].([]Ref)) != 0 {
+		t.Errorf("Expected no parents, Actual: %v", nodes[1]["parents"])
+	}
+
+	nodes = parseGitLog(gitLog, RepoOptions{SeqIds: true})
+	if nodes[0]["id"] != "0" || nodes[0]["sha"] != "bbbb" || nodes[0]["parents"].([]string)[0] != "1" {
+		t.Errorf("Unexpected sequential ids: %v", nodes[0])
+	}
+
+	if len(nodes[0]["refs"].([]Ref)) != 3 {
+		t.Errorf("Expected tags to be excluded, Actual: %v", nodes[0]["refs"])
+	}
+
+	if nodes := parseGitLog("", RepoOptions{}); len(nodes) != 0 {
+		t.Errorf("Expected no nodes, Actual: %v", nodes)
+	}
+}
+
+func TestGetInputNodesFromRepoWithOptions(t *testing.T) {
+	dir := newTestRepo(t)
+	inputs := []struct {
+		opts     RepoOptions
+		expected []string
+	}{
+		{RepoOptions{Path: dir}, []string{"E", "D", "C", "B", "A"}},
+		{RepoOptions{Path: dir, Revisions: []string{"master..feature"}}, []string{"E", "D"}},
+		{RepoOptions{Path: dir, Revisions: []string{"master"}}, []string{"C", "B", "A"}},
+		{RepoOptions{Path: dir, Revisions: []string{"--all"}, MaxCount: 2}, []string{"E", "D"}},
+		{RepoOptions{Path: dir, Since: "2015-01-02T00:00:00+00:00", Until: "2015-01-04T00:00:00+00:00"}, []string{"C", "B"}},
+	}
+	for _, input := range inputs {
+		nodes, err := GetInputNodesFromRepoWithOptions(input.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(subjects(nodes), input.expected) {
+			t.Errorf("Options: %+v, Expected: %v, Actual: %v", input.opts, input.expected, subjects(nodes))
+		}
+	}
+
+	if _, err := GetInputNodesFromRepoWithOptions(RepoOptions{Path: dir, Revisions: []string{"unknown"}}); err == nil {
+		t.Error("Expected an error for an unknown revision")
+	}
+}
+
+func TestGetInputNodesFromRepoLayout(t *testing.T) {
+	// The parents that are not read are dropped, the nodes can be laid out
+	dir := newTestRepo(t)
+	inputs := []struct {
+		opts     RepoOptions
+		expected map[string][]string // Subjects of the parents, by subject
+	}{
+		{RepoOptions{Path: dir, MaxCount: 2}, map[string][]string{"E": {"D"}, "D": {}}},
+		{RepoOptions{Path: dir, Revisions: []string{"master~1..master"}}, map[string][]string{"C": {}}},
+		{RepoOptions{Path: dir, Revisions: []string{"feature"}, SeqIds: true, MaxCount: 1}, map[string][]string{"E": {}}},
+		{RepoOptions{Path: dir, Since: "2015-01-02T00:00:00+00:00"}, map[string][]string{"E": {"D"}, "D": {}, "C": {"B"}, "B": {}}},
+	}
+	for _, input := range inputs {
+		nodes, err := GetInputNodesFromRepoWithOptions(input.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		subjectOf := make(map[string]string)
+		for _, node := range nodes {
+			subjectOf[node["id"].(string)] = node["subject"].(string)
+		}
+		parents := make(map[string][]string)
+		for _, node := range nodes {
+			parents[node["subject"].(string)] = make([]string, 0)
+			for _, parentID := range node["parents"].([]string) {
+				parents[node["subject"].(string)] = append(parents[node["subject"].(string)], subjectOf[parentID])
+			}
+		}
+		if !reflect.DeepEqual(parents, input.expected) {
+			t.Errorf("Options: %+v, Expected: %v, Actual: %v", input.opts, input.expected, parents)
+		}
+		if _, err := Get(nodes); err != nil {
+			t.Errorf("Options: %+v, %v", input.opts, err)
+		}
+	}
+}
+
+func TestGetInputNodesFromRepoSkewedDates(t *testing.T) {
+	dir := newTestRepo(t)
+	// D and E are dated before their parent B
+	runGit(t, dir, nil, "checkout", "-q", "-b", "skewed", "master~1")
+	for _, subject := range []string{"D", "E"} {
+		date := "2014-12-31T12:00:00+00:00"
+		runGit(t, dir, []string{"GIT_AUTHOR_DATE=" + date, "GIT_COMMITTER_DATE=" + date}, "commit", "-q", "--allow-empty", "-m", subject)
+	}
+	runGit(t, dir, nil, "checkout", "-q", "master")
+	runGit(t, dir, nil, "merge", "-q", "--no-ff", "-m", "F", "skewed")
+	for _, native := range []bool{false, true} {
+		nodes, err := GetInputNodesFromRepoWithOptions(RepoOptions{Path: dir, Revisions: []string{"master"}, Native: native})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if problems := Validate(nodes); len(problems) > 0 {
+			t.Errorf("Native: %v, Subjects: %v, Problems: %v", native, subjects(nodes), problems)
+		}
+	}
+}
+
+func TestGetInputNodesFromRepoOptionRevision(t *testing.T) {
+	dir := newTestRepo(t)
+	for _, native := range []bool{false, true} {
+		opts := RepoOptions{Path: dir, Revisions: []string{"--output=" + dir + "/out", "master"}, Native: native}
+		if _, err := GetInputNodesFromRepoWithOptions(opts); err == nil {
+			t.Errorf("Native: %v, Expected an error for an option passed as revision", native)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package git2graph
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ProblemType type of a problem found by Validate
+type ProblemType string
+
+// Problem types
+const (
+	MalformedNode     ProblemType = "malformed"           // id is not a string or parents not an array of string
+	DuplicateID       ProblemType = "duplicate-id"        // Another node before has the same id
+	SelfParent        ProblemType = "self-parent"         // The node is one of its parents
+	DanglingParent    ProblemType = "dangling-parent"     // The parent is not one of the nodes
+	ParentBeforeChild ProblemType = "parent-before-child" // The nodes are not in topological order
+	Cycle             ProblemType = "cycle"               // The parent is also a descendant of the node
+	DuplicateParent   ProblemType = "duplicate-parent"    // The parent is listed more than once
+)
+
+// Problem problem of an input node
+type Problem struct {
+	Idx     int         `json:"idx"` // Row of the node in the input
+	ID      string      `json:"id"`
+	Type    ProblemType `json:"type"`
+	Parent  string      `json:"parent,omitempty"` // Parent concerned, if any
+	Message string      `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("row %d (%s): %s", p.Idx, p.ID, p.Message)
+}
+
+// validNode node whose id and parents are well formed, and that is not a duplicate
+type validNode struct {
+	idx     int
+	id      string
+	parents []string
+}
+
+// Validate report the problems of the input nodes: malformed nodes, duplicate ids, self-parents, parents listed
+// twice, parents that are not one of the nodes, nodes that are not in topological order (children first) and cycles.
+// The layout rejects the duplicate ids, self-parents and dangling parents, and ignores the parents before their
+// children, so the cycles and the nodes that are not in order are laid out wrong.
+func Validate(nodes []map[string]interface{}) []Problem {
+	problems, _ := validate(nodes)
+	return problems
+}
+
+// validate report the problems, and return the well-formed nodes that are not duplicates with their valid parents
+func validate(nodes []map[string]interface{}) ([]Problem, []validNode) {
+	problems := make([]Problem, 0)
+	valid := make([]validNode, 0, len(nodes))
+	rows := make(map[string]int)
+	for idx, node := range nodes {
+		id, ok := node["id"].(string)
+		if !ok {
+			problems = append(problems, Problem{Idx: idx, Type: MalformedNode, Message: "id property must be a string"})
+			continue
+		}
+		parents, ok := node["parents"].([]string)
+		if !ok {
+			problems = append(problems, Problem{Idx: idx, ID: id, Type: MalformedNode, Message: "parents property must be an array of string"})
+			continue
+		}
+		if row, ok := rows[id]; ok {
+			problems = append(problems, Problem{Idx: idx, ID: id, Type: DuplicateID, Message: fmt.Sprintf("duplicate of row %d", row)})
+			continue
+		}
+		rows[id] = idx
+		valid = append(valid, validNode{idx, id, parents})
+	}
+
+	for i, node := range valid {
+		parents := make([]string, 0, len(node.parents))
+		seen := make(map[string]bool)
+		for _, parentID := range node.parents {
+			row, ok := rows[parentID]
+			switch {
+			case seen[parentID]:
+				problems = append(problems, Problem{node.idx, node.id, DuplicateParent, parentID, fmt.Sprintf("parent %s is listed twice", parentID)})
+			case parentID == node.id:
+				problems = append(problems, Problem{node.idx, node.id, SelfParent, parentID, "the node is its own parent"})
+			case !ok:
+				problems = append(problems, Problem{node.idx, node.id, DanglingParent, parentID, fmt.Sprintf("parent %s is not one of the nodes", parentID)})
+			default:
+				parents = append(parents, parentID)
+				if row < node.idx {
+					problems = append(problems, Problem{node.idx, node.id, ParentBeforeChild, parentID, fmt.Sprintf("parent %s is before the node, at row %d", parentID, row)})
+				}
+			}
+			seen[parentID] = true
+		}
+		valid[i].parents = parents
+	}
+
+	// Depth-first search from the first nodes, a parent on the stack closes a cycle.
+	// The stack is explicit, a linear history is as deep as it is long.
+	byID := make(map[string]int)
+	for i, node := range valid {
+		byID[node.id] = i
+	}
+	const (
+		unvisited = iota
+		onStack
+		done
+	)
+	type frame struct {
+		node int
+		next int // Index of the next parent to visit
+	}
+	state := make([]int, len(valid))
+	for root := range valid {
+		if state[root] != unvisited {
+			continue
+		}
+		state[root] = onStack
+		stack := []frame{{root, 0}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			i := top.node
+			if top.next == len(valid[i].parents) {
+				state[i] = done
+				stack = stack[:len(stack)-1]
+				continue
+			}
+			parentID := valid[i].parents[top.next]
+			parent := byID[parentID]
+			switch state[parent] {
+			case unvisited:
+				top.next++
+				state[parent] = onStack
+				stack = append(stack, frame{parent, 0})
+			case onStack:
+				start := len(stack) - 1
+				for stack[start].node != parent {
+					start--
+				}
+				ids := make([]string, 0, len(stack)-start+1)
+				for _, f := range stack[start:] {
+					ids = append(ids, valid[f.node].id)
+				}
+				cycle := strings.Join(append(ids, parentID), " -> ")
+				problems = append(problems, Problem{valid[i].idx, valid[i].id, Cycle, parentID, "cycle " + cycle})
+				// The edge closing the cycle is not a parent anymore
+				valid[i].parents = append(valid[i].parents[:top.next:top.next], valid[i].parents[top.next+1:]...)
+			default:
+				top.next++
+			}
+		}
+	}
+
+	// A parent closing a cycle is only reported as a cycle, the problems are reported by row
+	closing := make(map[Problem]bool)
+	for _, problem := range problems {
+		if problem.Type == Cycle {
+			closing[Problem{Idx: problem.Idx, ID: problem.ID, Parent: problem.Parent}] = true
+		}
+	}
+	reported := make([]Problem, 0, len(problems))
+	for _, problem := range problems {
+		if problem.Type != ParentBeforeChild || !closing[Problem{Idx: problem.Idx, ID: problem.ID, Parent: problem.Parent}] {
+			reported = append(reported, problem)
+		}
+	}
+	sort.SliceStable(reported, func(i, j int) bool { return reported[i].Idx < reported[j].Idx })
+	return reported, valid
+}
+
+// RepairOptions options of Repair
+type RepairOptions struct {
+	Stub bool // Add a node without parents for the dangling parents instead of removing them
+}
+
+// Repair fix the problems found by Validate, and return the fixed nodes with the problems.
+// Malformed nodes and duplicates are dropped, self-parents, duplicate parents and the parents closing a cycle are
+// removed, dangling parents are removed or stubbed by nodes marked "stub", and the nodes are reordered so children
+// come first.
+// The input nodes are not modified.
+func Repair(nodes []map[string]interface{}, opts RepairOptions) ([]map[string]interface{}, []Problem) {
+	problems, valid := validate(nodes)
+	inputs := make([]map[string]interface{}, 0, len(valid))
+	for _, node := range valid {
+		inputs = append(inputs, nodes[node.idx])
+	}
+	if opts.Stub {
+		stubbed := make(map[string]bool)
+		for _, problem := range problems {
+			if problem.Type == DanglingParent {
+				stubbed[problem.Parent] = true
+			}
+		}
+		for i, node := range valid {
+			parents := make([]string, 0, len(node.parents))
+			for _, parentID := range nodes[node.idx]["parents"].([]string) {
+				if (stubbed[parentID] || containsParent(node.parents, parentID)) && !containsParent(parents, parentID) {
+					parents = append(parents, parentID)
+				}
+			}
+			valid[i].parents = parents
+		}
+		for _, problem := range problems {
+			if problem.Type == DanglingParent && stubbed[problem.Parent] {
+				delete(stubbed, problem.Parent)
+				valid = append(valid, validNode{-1, problem.Parent, []string{}})
+				inputs = append(inputs, map[string]interface{}{"id": problem.Parent, "stub": true})
+			}
+		}
+	}
+
+	// Topological sort, a node is ready once all its children are placed, the first ready node in the input is placed first
+	byID := make(map[string]int)
+	for i, node := range valid {
+		byID[node.id] = i
+	}
+	children := make([]int, len(valid))
+	for _, node := range valid {
+		for _, parentID := range node.parents {
+			children[byID[parentID]]++
+		}
+	}
+	ready := &intHeap{}
+	for i := range valid {
+		if children[i] == 0 {
+			heap.Push(ready, i)
+		}
+	}
+	out := make([]map[string]interface{}, 0, len(valid))
+	for ready.Len() > 0 {
+		i := heap.Pop(ready).(int)
+		node := make(map[string]interface{}, len(inputs[i]))
+		for key, value := range inputs[i] {
+			node[key] = value
+		}
+		node["parents"] = valid[i].parents
+		out = append(out, node)
+		for _, parentID := range valid[i].parents {
+			parent := byID[parentID]
+			if children[parent]--; children[parent] == 0 {
+				heap.Push(ready, parent)
+			}
+		}
+	}
+	return out, problems
+}
+
+func containsParent(parents []string, id string) bool {
+	for _, parentID := range parents {
+		if parentID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// intHeap min-heap of ints
+type intHeap []int
+
+func (h intHeap) Len() int            { return len(h) }
+func (h intHeap) Less(i, j int) bool  { return h[i] < h[j] }
+func (h intHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *intHeap) Push(x interface{}) { *h = append(*h, x.(int)) }
+func (h *intHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
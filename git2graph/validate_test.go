@@ -0,0 +1,125 @@
+package git2graph
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func invalidNodes() []map[string]interface{} {
+	inputNodes := make([]map[string]interface{}, 0)
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "1", "parents": []string{"2", "9", "9"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "3", "parents": []string{"3"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "2", "parents": []string{"4"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "2", "parents": []string{}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "4", "parents": []string{"1"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "5", "parents": []string{"6", "6"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "6", "parents": []string{}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": "7", "parents": []string{"5"}})
+	inputNodes = append(inputNodes, map[string]interface{}{"id": 8, "parents": []string{}})
+	return inputNodes
+}
+
+func TestValidate(t *testing.T) {
+	expected := []Problem{
+		{0, "1", DanglingParent, "9", "parent 9 is not one of the nodes"},
+		{0, "1", DuplicateParent, "9", "parent 9 is listed twice"},
+		{1, "3", SelfParent, "3", "the node is its own parent"},
+		{3, "2", DuplicateID, "", "duplicate of row 2"},
+		{4, "4", Cycle, "1", "cycle 1 -> 2 -> 4 -> 1"},
+		{5, "5", DuplicateParent, "6", "parent 6 is listed twice"},
+		{7, "7", ParentBeforeChild, "5", "parent 5 is before the node, at row 5"},
+		{8, "", MalformedNode, "", "id property must be a string"},
+	}
+	problems := Validate(invalidNodes())
+	if !reflect.DeepEqual(problems, expected) {
+		t.Errorf("Expected: %v, Actual: %v", expected, problems)
+	}
+
+	nodes, _ := GetInputNodesFromFile("../data/example_001.json")
+	if problems := Validate(nodes); len(problems) != 0 {
+		t.Errorf("Expected no problems, Actual: %v", problems)
+	}
+}
+
+func TestRepair(t *testing.T) {
+	inputs := []struct {
+		opts     RepairOptions
+		expected []map[string]interface{}
+	}{
+		{RepairOptions{}, []map[string]interface{}{
+			{"id": "1", "parents": []string{"2"}},
+			{"id": "3", "parents": []string{}},
+			{"id": "2", "parents": []string{"4"}},
+			{"id": "4", "parents": []string{}},
+			{"id": "7", "parents": []string{"5"}},
+			{"id": "5", "parents": []string{"6"}},
+			{"id": "6", "parents": []string{}},
+		}},
+		{RepairOptions{Stub: true}, []map[string]interface{}{
+			{"id": "1", "parents": []string{"2", "9"}},
+			{"id": "3", "parents": []string{}},
+			{"id": "2", "parents": []string{"4"}},
+			{"id": "4", "parents": []string{}},
+			{"id": "7", "parents": []string{"5"}},
+			{"id": "5", "parents": []string{"6"}},
+			{"id": "6", "parents": []string{}},
+			{"id": "9", "parents": []string{}, "stub": true},
+		}},
+	}
+	for _, input := range inputs {
+		inputNodes := invalidNodes()
+		nodes, problems := Repair(inputNodes, input.opts)
+		if !reflect.DeepEqual(nodes, input.expected) {
+			t.Errorf("Expected: %v, Actual: %v", input.expected, nodes)
+		}
+		if len(problems) != 8 || len(Validate(nodes)) != 0 {
+			t.Errorf("Expected 8 problems fixed, Actual: %v, remaining: %v", problems, Validate(nodes))
+		}
+		if !reflect.DeepEqual(inputNodes, invalidNodes()) {
+			t.Error("Expected the input nodes to be unchanged")
+		}
+		if _, err := Get(nodes); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestValidateLongHistory(t *testing.T) {
+	// A linear history closed by a cycle, the search does not recurse once per commit
+	const count = 200000
+	nodes := make([]map[string]interface{}, 0, count)
+	for i := 0; i < count; i++ {
+		parents := []string{fmt.Sprint(i + 1)}
+		if i == count-1 {
+			parents = []string{"0"}
+		}
+		nodes = append(nodes, map[string]interface{}{"id": fmt.Sprint(i), "parents": parents})
+	}
+	problems := Validate(nodes)
+	if len(problems) != 1 || problems[0].Type != Cycle || problems[0].Idx != count-1 || problems[0].Parent != "0" {
+		t.Errorf("Expected the cycle closed by the last node, Actual: %v", problems)
+	}
+}
+
+func TestLayoutRejectsInvalidNodes(t *testing.T) {
+	inputs := []struct {
+		nodes []map[string]interface{}
+		idx   int
+		field string
+	}{
+		{[]map[string]interface{}{{"id": "1", "parents": []string{"2"}}, {"id": "1", "parents": []string{}}, {"id": "2", "parents": []string{}}}, 1, "id"},
+		{[]map[string]interface{}{{"id": "1", "parents": []string{"1"}}}, 0, "parents"},
+	}
+	for _, input := range inputs {
+		_, err := Get(input.nodes)
+		inputErr, ok := err.(*InputError)
+		if !ok || inputErr.Idx != input.idx || inputErr.Field != input.field {
+			t.Errorf("Expected %s error on node %d, Actual: %v", input.field, input.idx, err)
+		}
+	}
+	_, err := Build([]InputNode{{ID: "1", Parents: []string{}}, {ID: "1", Parents: []string{}}})
+	if inputErr, ok := err.(*InputError); !ok || inputErr.Idx != 1 {
+		t.Errorf("Expected id error on node 1, Actual: %v", err)
+	}
+}
@@ -1,8 +1,17 @@
 package main
 
 import (
+	"bufio"
+	"encoding/json"
+	"fmt"
 	"git2graph/git2graph"
+	"git2graph/render"
+	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/codegangsta/cli"
@@ -11,29 +20,62 @@ import (
 func bootstrap(c *cli.Context) error {
 	var nodes []map[string]interface{}
 	var err error
-	fromFlag := c.Int("from")
+	fromFlag := c.String("from")
+	cursorFlag := c.String("cursor")
 	sizeFlag := c.Int("size")
 	contextFlag := c.Bool("context")
 	jsonFlag := c.String("json")
 	fileFlag := c.String("file")
+	outputFlag := c.String("output")
 	git2graph.DebugMode = c.Bool("debug")
 	repoFlag := c.Bool("repo")
 	git2graph.NoOutput = c.Bool("no-output")
 	repoLinearFlag := c.Bool("repo-linear")
 	seqIds := c.Bool("seq-ids")
+	tagsFlag := c.Bool("tags")
 	logLevel := c.String("log")
 	setLogLevel(logLevel)
+	// Repair needs all the nodes at once
+	ndjsonInput := fileFlag != "" && c.Bool("ndjson") && c.String("repair") == "" && !repoFlag && !repoLinearFlag && jsonFlag == ""
+
+	cfg, err := loadConfig(c)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+
+	repoOpts := git2graph.RepoOptions{
+		SeqIds:       seqIds,
+		Tags:         tagsFlag,
+		Path:         c.String("path"),
+		Revisions:    append(c.StringSlice("rev"), c.Args()...),
+		MaxCount:     c.Int("max-count"),
+		Since:        c.String("since"),
+		Until:        c.String("until"),
+		Native:       c.Bool("native"),
+		TopologyOnly: c.Bool("topology-only"),
+	}
+	if len(repoOpts.Revisions) == 0 {
+		repoOpts.Revisions = cfg.Revisions
+	}
 
 	if repoFlag {
-		nodes, err = git2graph.GetInputNodesFromRepo(seqIds)
+		nodes, err = git2graph.GetInputNodesFromRepoWithOptions(repoOpts)
 	} else if repoLinearFlag {
-		nodes, err = git2graph.GetInputNodesFromRepo(seqIds)
-		git2graph.SerializeOutput(nodes)
-		return err
+		nodes, err = git2graph.GetInputNodesFromRepoWithOptions(repoOpts)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		return writeOutput(outputFlag, func(w io.Writer) error {
+			return git2graph.SerializeOutput(w, nodes)
+		})
 	} else if jsonFlag != "" {
 		nodes, err = git2graph.GetInputNodesFromJSON([]byte(jsonFlag))
+	} else if ndjsonInput {
+		// Read by batches while laying out, see layoutNDJSON
 	} else if fileFlag != "" {
-		nodes, err = git2graph.GetInputNodesFromFile(fileFlag)
+		nodes, err = getInputNodesFromFile(fileFlag, c.Bool("ndjson"))
 	} else {
 		cli.ShowAppHelp(c)
 		return err
@@ -42,10 +84,57 @@ func bootstrap(c *cli.Context) error {
 		log.Error(err)
 		return err
 	}
+	if repairFlag := c.String("repair"); repairFlag != "" {
+		nodes, err = repair(nodes, repairFlag)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+	}
 
 	myColors := git2graph.DefaultColors
+	if len(cfg.Palette) > 0 {
+		myColors = git2graph.NewPalette(cfg.Palette)
+	}
+	allocator, err := colorAllocator(c.String("colors"), myColors)
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	layout := git2graph.NewLayout(git2graph.Options{Colors: myColors, Allocator: allocator, Debug: git2graph.DebugMode})
 
-	out, err := git2graph.BuildTree(nodes, myColors)
+	colorByFlag := c.String("color-by")
+	paginated := fromFlag != "" || cursorFlag != "" || sizeFlag >= 1
+	if c.String("format") == "ndjson" && !paginated && colorByFlag == "" {
+		// Rows are written as soon as they are final instead of buffering the whole graph
+		err = writeOutput(outputFlag, func(w io.Writer) error {
+			enc := json.NewEncoder(w)
+			if ndjsonInput {
+				return layoutNDJSON(layout, fileFlag, func(node map[string]interface{}) error {
+					return enc.Encode(node)
+				})
+			}
+			return layout.StreamTree(nodes, func(node map[string]interface{}) error {
+				return enc.Encode(node)
+			})
+		})
+		if err != nil {
+			log.Error(err)
+		}
+		return err
+	}
+
+	var out []map[string]interface{}
+	if ndjsonInput {
+		out = make([]map[string]interface{}, 0)
+		err = layoutNDJSON(layout, fileFlag, func(node map[string]interface{}) error {
+			out = append(out, node)
+			return nil
+		})
+		sort.Slice(out, func(i, j int) bool { return out[i]["idx"].(int) < out[j]["idx"].(int) })
+	} else {
+		out, err = layout.BuildTree(nodes)
+	}
 	if err != nil {
 		log.Error(err)
 		return err
@@ -53,36 +142,277 @@ func bootstrap(c *cli.Context) error {
 	for _, node := range out {
 		delete(node, "parentsPaths")
 	}
+	var legend []git2graph.LegendEntry
+	if colorByFlag != "" {
+		legend = git2graph.ColorBy(out, colorByFlag, myColors)
+	}
 
-	var tmp []map[string]interface{}
-	if fromFlag >= 0 && sizeFlag >= 1 {
-		// TODO: include context (nodes before "from" that have parents inside or after the range)
+	tmp := out
+	var page *git2graph.Page
+	if paginated {
+		cursor := cursorFlag
+		if fromFlag != "" {
+			cursor = git2graph.Cursor(fromFlag)
+		}
+		if sizeFlag < 1 {
+			sizeFlag = len(out) + 1
+		}
+		page, err = git2graph.Paginate(out, cursor, sizeFlag)
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		tmp = page.Nodes
 		if contextFlag {
-			for _, node := range out {
-				hasParentsInContext := false
-				for _, nodeParent := range node["parents_paths"].([]git2graph.Path) {
-					if nodeParent.Path[len(nodeParent.Path)-1].Y >= fromFlag {
-						hasParentsInContext = true
-					}
-				}
-				if hasParentsInContext ||
-					node["idx"].(int) >= fromFlag && node["idx"].(int) < fromFlag+sizeFlag {
-					tmp = append(tmp, node)
-				}
-				if node["idx"].(int) >= fromFlag+sizeFlag-1 {
-					break
-				}
+			// Clipped lanes crossing the page, so they are drawn up to its borders
+			tmp = page.Rows()
+		}
+	}
+
+	err = writeOutput(outputFlag, func(w io.Writer) error {
+		if c.String("format") == "json" {
+			// The page is written with its lanes and the cursor of the next page, its nodes are the rows with --context
+			if page != nil {
+				rows := *page
+				rows.Nodes = tmp
+				return json.NewEncoder(w).Encode(struct {
+					*git2graph.Page
+					Legend []git2graph.LegendEntry `json:"legend,omitempty"`
+				}{&rows, legend})
+			}
+			if legend != nil {
+				return json.NewEncoder(w).Encode(struct {
+					Nodes  []map[string]interface{} `json:"nodes"`
+					Legend []git2graph.LegendEntry  `json:"legend"`
+				}{tmp, legend})
+			}
+		}
+		return serialize(c, w, tmp, legend)
+	})
+	if err != nil {
+		log.Error(err)
+	}
+
+	return err
+}
+
+func serialize(c *cli.Context, w io.Writer, nodes []map[string]interface{}, legend []git2graph.LegendEntry) error {
+	switch c.String("format") {
+	case "json":
+		return git2graph.SerializeOutput(w, nodes)
+	case "ndjson":
+		enc := json.NewEncoder(w)
+		for _, node := range nodes {
+			if err := enc.Encode(node); err != nil {
+				return err
 			}
-		} else {
-			tmp = out[fromFlag : fromFlag+sizeFlag]
 		}
+	case "text":
+		return render.Text(w, nodes, render.TextOptions{ASCII: c.Bool("ascii"), Color: c.Bool("color"), Legend: legend})
+	case "svg":
+		opts := render.DefaultSVGOptions
+		opts.Labels = !c.Bool("no-labels")
+		opts.Legend = legend
+		opts.Geometry = geometry(c)
+		return render.SVG(w, nodes, opts)
+	case "png":
+		opts := render.DefaultPNGOptions
+		opts.Geometry = geometry(c)
+		return render.PNG(w, nodes, opts)
+	case "dot":
+		return render.DOT(w, nodes, render.DOTOptions{Geometry: geometry(c)})
+	case "mermaid":
+		return render.Mermaid(w, nodes, render.MermaidOptions{Markdown: c.Bool("markdown")})
+	default:
+		return fmt.Errorf("unknown format %s", c.String("format"))
+	}
+	return nil
+}
+
+// ndjsonBatchSize number of nodes read at once from a ndjson input
+const ndjsonBatchSize = 1000
+
+// openInput open a file, or stdin when filePath is "-"
+func openInput(filePath string) (io.ReadCloser, error) {
+	if filePath == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filePath)
+}
+
+// getInputNodesFromFile read the nodes from a file, or from stdin when filePath is "-"
+func getInputNodesFromFile(filePath string, ndjson bool) ([]map[string]interface{}, error) {
+	r, err := openInput(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	if ndjson {
+		return git2graph.GetInputNodesFromNDJSON(r)
+	}
+	return git2graph.GetInputNodesFromReader(r)
+}
+
+// layoutNDJSON lay out the nodes of a ndjson file by batches, fn is called with every node as soon as it is final.
+// Only the batch read and the nodes of the open lanes are in memory.
+func layoutNDJSON(layout *git2graph.Layout, filePath string, fn func(map[string]interface{}) error) error {
+	r, err := openInput(filePath)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	err = git2graph.ReadNDJSON(r, ndjsonBatchSize, func(batch []map[string]interface{}) error {
+		return layout.AppendStream(batch, fn)
+	})
+	if err != nil {
+		return err
+	}
+	return layout.Finish()
+}
+
+// writeOutput call write with stdout, or with a temporary file that replaces path once complete,
+// so readers of path never see a partial output
+func writeOutput(path string, write func(io.Writer) error) error {
+	if git2graph.NoOutput {
+		return nil
+	}
+	if path == "" || path == "-" {
+		bw := bufio.NewWriter(os.Stdout)
+		if err := write(bw); err != nil {
+			return err
+		}
+		return bw.Flush()
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name()) // Fails once renamed
+	bw := bufio.NewWriter(f)
+	err = write(bw)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// validate report the problems of the input nodes, or write the repaired nodes with --repair
+func validate(c *cli.Context) error {
+	var nodes []map[string]interface{}
+	var err error
+	if c.String("json") != "" {
+		nodes, err = git2graph.GetInputNodesFromJSON([]byte(c.String("json")))
+	} else if c.String("file") != "" {
+		nodes, err = getInputNodesFromFile(c.String("file"), c.Bool("ndjson"))
 	} else {
-		tmp = out
+		cli.ShowCommandHelp(c, c.Command.Name)
+		return nil
 	}
+	if err != nil {
+		log.Error(err)
+		return err
+	}
+	if c.String("repair") != "" {
+		nodes, err = repair(nodes, c.String("repair"))
+		if err != nil {
+			log.Error(err)
+			return err
+		}
+		return writeOutput(c.String("output"), func(w io.Writer) error {
+			return git2graph.SerializeOutput(w, nodes)
+		})
+	}
+	problems := git2graph.Validate(nodes)
+	for _, problem := range problems {
+		fmt.Println(problem)
+	}
+	if len(problems) > 0 {
+		return cli.NewExitError("", 1)
+	}
+	return nil
+}
 
-	git2graph.SerializeOutput(tmp)
+// repair fix the input nodes, the dangling parents are dropped or stubbed depending on mode
+func repair(nodes []map[string]interface{}, mode string) ([]map[string]interface{}, error) {
+	if mode != "drop" && mode != "stub" {
+		return nil, fmt.Errorf("unknown repair mode %s", mode)
+	}
+	nodes, problems := git2graph.Repair(nodes, git2graph.RepairOptions{Stub: mode == "stub"})
+	for _, problem := range problems {
+		log.Warn("Repaired ", problem)
+	}
+	return nodes, nil
+}
 
-	return err
+// loadConfig read the --config file, or the config of the user and of the repository, and use it for the flags
+// that are not set
+func loadConfig(c *cli.Context) (git2graph.Config, error) {
+	var cfg git2graph.Config
+	var err error
+	if c.String("config") != "" {
+		cfg, err = git2graph.ReadConfig(c.String("config"))
+	} else {
+		dir := c.String("path")
+		if dir == "" {
+			dir = "."
+		}
+		cfg, err = git2graph.LoadConfig(dir)
+	}
+	if err != nil {
+		return cfg, err
+	}
+	defaults := map[string]string{"format": cfg.Format, "colors": cfg.Colors, "color-by": cfg.ColorBy}
+	if cfg.ColumnWidth != 0 {
+		defaults["column-width"] = strconv.Itoa(cfg.ColumnWidth)
+	}
+	if cfg.RowHeight != 0 {
+		defaults["row-height"] = strconv.Itoa(cfg.RowHeight)
+	}
+	if cfg.Radius != 0 {
+		defaults["radius"] = strconv.Itoa(cfg.Radius)
+	}
+	for name, value := range defaults {
+		if value != "" && !c.IsSet(name) {
+			if err := c.Set(name, value); err != nil {
+				return cfg, fmt.Errorf("config %s: %v", name, err)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// colorAllocator allocator of the --colors flag
+func colorAllocator(name string, palette []git2graph.Color) (git2graph.ColorAllocator, error) {
+	switch name {
+	case "palette":
+		return git2graph.NewPaletteAllocator(palette), nil
+	case "generate":
+		return git2graph.NewGeneratedAllocator(palette), nil
+	case "stable":
+		return git2graph.NewStableAllocator(palette), nil
+	default:
+		return nil, fmt.Errorf("unknown colors %s", name)
+	}
+}
+
+func geometry(c *cli.Context) render.Geometry {
+	return render.Geometry{
+		ColumnWidth: c.Int("column-width"),
+		RowHeight:   c.Int("row-height"),
+		Radius:      c.Int("radius"),
+	}
 }
 
 func setLogLevel(logLevel string) {
@@ -111,7 +441,7 @@ func init() {
 func main() {
 	var authors []cli.Author
 	// Collaborators, add your name here :)
-	authors = append(authors, cli.Author{"Alain Gilbert", "alain.gilbert.15@gmail.com"})
+	authors = append(authors, cli.Author{Name: "Alain Gilbert", Email: "alain.gilbert.15@gmail.com"})
 
 	app := cli.NewApp()
 	app.Authors = authors
@@ -121,12 +451,24 @@ func main() {
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:  "f, file",
-			Usage: "File",
+			Usage: "File, - to read from stdin",
+		},
+		cli.StringFlag{
+			Name:  "config",
+			Usage: "Config file (default: .git2graph.yml of the repository, and git2graph/config.yml of the user config directory)",
+		},
+		cli.StringFlag{
+			Name:  "o, output",
+			Usage: "Output file, replaced atomically once the output is complete (default: stdout)",
 		},
 		cli.StringFlag{
 			Name:  "j, json",
 			Usage: "Json input",
 		},
+		cli.BoolFlag{
+			Name:  "ndjson",
+			Usage: "The file is newline-delimited json, one node per line",
+		},
 		cli.StringFlag{
 			Name:  "L, log",
 			Usage: "Log level",
@@ -147,26 +489,138 @@ func main() {
 			Name:  "s, seq-ids",
 			Usage: "Use sequentiel ids instead of sha for linear history",
 		},
+		cli.BoolFlag{
+			Name:  "t, tags",
+			Usage: "Include tags when reading a repository",
+		},
+		cli.StringFlag{
+			Name:  "C, path",
+			Usage: "Repository path",
+		},
+		cli.StringSliceFlag{
+			Name:  "rev",
+			Usage: "Revision specs (main..feature, --all, v1.0...v2.0), can also be given as arguments",
+		},
+		cli.IntFlag{
+			Name:  "max-count",
+			Usage: "Limit the number of commits read from the repository",
+		},
+		cli.StringFlag{
+			Name:  "since",
+			Usage: "Only commits more recent than a specific date",
+		},
+		cli.StringFlag{
+			Name:  "until",
+			Usage: "Only commits older than a specific date",
+		},
+		cli.BoolFlag{
+			Name:  "native",
+			Usage: "Read the .git directory directly instead of running git (the commit-graph is only faster with --topology-only)",
+		},
+		cli.BoolFlag{
+			Name:  "topology-only",
+			Usage: "Only read ids, parents and refs from the repository, without inflating the commits",
+		},
+		cli.StringFlag{
+			Name:  "F, format",
+			Usage: "Output format (json, ndjson, text, svg, png, dot, mermaid)",
+			Value: "json",
+		},
+		cli.StringFlag{
+			Name:  "colors",
+			Usage: "Colors of the lanes: palette (black once all the colors are in use), generate (new colors once all the colors are in use), stable (from the branch names)",
+			Value: "palette",
+		},
+		cli.StringFlag{
+			Name:  "color-by",
+			Usage: "Color the nodes by an attribute (eg: author_email) instead of by lane, with a legend (json, text and svg formats)",
+		},
+		cli.BoolFlag{
+			Name:  "ascii",
+			Usage: "Use ASCII characters instead of box-drawing characters (text format)",
+		},
+		cli.BoolFlag{
+			Name:  "color",
+			Usage: "Color the lanes with ANSI escape codes (text format)",
+		},
+		cli.BoolFlag{
+			Name:  "no-labels",
+			Usage: "Only draw the graph, without ids, refs and subjects (svg format)",
+		},
+		cli.BoolFlag{
+			Name:  "markdown",
+			Usage: "Wrap the graph in a fenced code block (mermaid format)",
+		},
+		cli.IntFlag{
+			Name:  "column-width",
+			Usage: "Distance between two lanes in pixels (svg, png and dot formats)",
+			Value: render.DefaultGeometry.ColumnWidth,
+		},
+		cli.IntFlag{
+			Name:  "row-height",
+			Usage: "Distance between two rows in pixels (svg, png and dot formats)",
+			Value: render.DefaultGeometry.RowHeight,
+		},
+		cli.IntFlag{
+			Name:  "radius",
+			Usage: "Radius of the nodes in pixels (svg, png and dot formats)",
+			Value: render.DefaultGeometry.Radius,
+		},
 		cli.BoolFlag{
 			Name:  "n, no-output",
 			Usage: "No output",
 		},
-		// TODO: From should be a sha
-		cli.IntFlag{
+		cli.StringFlag{
 			Name:  "from",
-			Usage: "From",
-			Value: -1,
+			Usage: "Id of the first node of the page",
+		},
+		cli.StringFlag{
+			Name:  "cursor",
+			Usage: "Cursor of the page, \"next\" of the previous page",
 		},
 		cli.IntFlag{
 			Name:  "size",
-			Usage: "Size",
+			Usage: "Maximum number of nodes of the page",
 			Value: -1,
 		},
+		cli.StringFlag{
+			Name:  "repair",
+			Usage: "Fix the input before the layout (see the validate command), the missing parents are dropped or stubbed (drop, stub)",
+		},
 		cli.BoolFlag{
 			Name:  "context",
-			Usage: "Include context",
+			Usage: "Include the paths crossing the page, from the nodes before it and to the nodes after it",
 		},
 	}
 	app.Action = bootstrap
+	app.Commands = []cli.Command{
+		{
+			Name:  "validate",
+			Usage: "Report duplicate ids, missing parents, cycles, self-parents, parents listed twice and nodes out of order",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "f, file",
+					Usage: "File, - to read from stdin",
+				},
+				cli.StringFlag{
+					Name:  "j, json",
+					Usage: "Json input",
+				},
+				cli.BoolFlag{
+					Name:  "ndjson",
+					Usage: "The file is newline-delimited json, one node per line",
+				},
+				cli.StringFlag{
+					Name:  "repair",
+					Usage: "Write the fixed nodes instead, the missing parents are dropped or stubbed (drop, stub)",
+				},
+				cli.StringFlag{
+					Name:  "o, output",
+					Usage: "Output file of the fixed nodes (default: stdout)",
+				},
+			},
+			Action: validate,
+		},
+	}
 	app.Run(os.Args)
 }
@@ -0,0 +1,70 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"git2graph/git2graph"
+	"io"
+	"strings"
+)
+
+// DOTOptions options of the graphviz renderer
+type DOTOptions struct {
+	Geometry // Positions of the nodes, in points
+}
+
+// DefaultDOTOptions same sizes as tools/renderer
+var DefaultDOTOptions = DOTOptions{Geometry: DefaultGeometry}
+
+// quote graphviz double-quoted string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// DOT write a graphviz digraph of the nodes, with edges from children to parents.
+// The nodes are pinned to the computed layout, so `neato -n` reproduces it.
+func DOT(w io.Writer, nodes []map[string]interface{}, opts DOTOptions) error {
+	rnodes, err := readNodes(nodes)
+	if err != nil {
+		return err
+	}
+	rnodes = withoutContext(rnodes)
+	if opts.Geometry == (Geometry{}) {
+		opts.Geometry = DefaultGeometry
+	}
+	minIdx, maxIdx, maxCol := bounds(rnodes)
+	_, height := opts.size(maxIdx-minIdx+1, maxCol)
+	ids := make(map[string]bool)
+	for _, n := range rnodes {
+		ids[n.id] = true
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.WriteString("digraph git2graph {\n")
+	fmt.Fprintf(bw, "  node [shape=circle, style=filled, fixedsize=true, width=%.3g, label=\"\"];\n", float64(2*opts.Radius)/72)
+	bw.WriteString("  edge [arrowhead=none, penwidth=2];\n")
+	for _, n := range rnodes {
+		// Graphviz y axis goes up
+		x, y := opts.point(git2graph.Point{X: n.column, Y: n.idx}, minIdx)
+		attrs := []string{
+			fmt.Sprintf("pos=\"%g,%g\"", x, float64(height)-y),
+			"fillcolor=" + quote(colorOr(n.color)),
+			"tooltip=" + quote(n.subject),
+		}
+		if len(n.refs) > 0 {
+			attrs = append(attrs, "xlabel="+quote(refsLabel(n.refs)))
+		}
+		fmt.Fprintf(bw, "  %s [%s];\n", quote(n.id), strings.Join(attrs, ", "))
+	}
+	for _, n := range rnodes {
+		for _, path := range n.paths {
+			// Parents outside of the nodes (eg: next page) have no position
+			if !ids[path.ID] {
+				continue
+			}
+			fmt.Fprintf(bw, "  %s -> %s [color=%s];\n", quote(n.id), quote(path.ID), quote(colorOr(path.Color)))
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
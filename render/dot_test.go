@@ -0,0 +1,36 @@
+package render
+
+import (
+	"bytes"
+	"git2graph/git2graph"
+	"testing"
+)
+
+func TestDOT(t *testing.T) {
+	nodes := buildTree(t, `[
+		{"id": "1", "parents": ["3", "2"], "subject": "Merge \"feature\""},
+		{"id": "2", "parents": ["3"]},
+		{"id": "3", "parents": ["4"]},
+		{"id": "4", "parents": []}
+	]`)[:3]
+	nodes[1]["refs"] = []git2graph.Ref{{Name: "v1.0", Type: git2graph.TagRef}}
+	var buf bytes.Buffer
+	if err := DOT(&buf, nodes, DefaultDOTOptions); err != nil {
+		t.Fatal(err)
+	}
+	// The edge from 3 to 4 is dropped, 4 is not in the nodes
+	expected := `digraph git2graph {
+  node [shape=circle, style=filled, fixedsize=true, width=0.111, label=""];
+  edge [arrowhead=none, penwidth=2];
+  "1" [pos="5,45", fillcolor="#5aa1be", tooltip="Merge \"feature\""];
+  "2" [pos="16,25", fillcolor="#c065b8", tooltip="", xlabel="tag: v1.0"];
+  "3" [pos="5,5", fillcolor="#5aa1be", tooltip=""];
+  "1" -> "3" [color="#5aa1be"];
+  "1" -> "2" [color="#c065b8"];
+  "2" -> "3" [color="#c065b8"];
+}
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,183 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"git2graph/git2graph"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// MermaidOptions options of the mermaid renderer
+type MermaidOptions struct {
+	Markdown bool // Wrap the graph in a ```mermaid fenced code block
+}
+
+// mermaidDefaultBranch first branch of a mermaid gitGraph
+const mermaidDefaultBranch = "main"
+
+var mermaidInvalidChars = regexp.MustCompile(`[^\w\-./]+`)
+
+// mermaidBranchName mermaid branch names are words separated by - . or /
+func mermaidBranchName(name string) string {
+	name = strings.TrimRight(mermaidInvalidChars.ReplaceAllString(name, "-"), "./")
+	if name == "" {
+		return "lane"
+	}
+	return name
+}
+
+// chains split the nodes into first-parent chains, starting with the chain of HEAD, then newest first.
+// A chain is named after the first local branch found from its tip, or after the lane of its tip.
+func chains(nodes []node, index map[string]node) (chainOf map[string]int, names []string) {
+	chainOf = make(map[string]int)
+	used := make(map[string]bool)
+	tips := make([]node, 0, len(nodes))
+	for _, n := range nodes {
+		for _, ref := range n.refs {
+			if ref.Type == git2graph.HeadRef {
+				tips = append(tips, n)
+				break
+			}
+		}
+	}
+	for _, n := range append(tips, nodes...) {
+		if _, ok := chainOf[n.id]; ok {
+			continue
+		}
+		chain := len(names)
+		name := ""
+		for id := n.id; ; {
+			chainOf[id] = chain
+			if name == "" {
+				for _, ref := range index[id].refs {
+					if ref.Type == git2graph.LocalBranchRef {
+						name = mermaidBranchName(ref.Name)
+						break
+					}
+				}
+			}
+			parents := index[id].parents
+			if len(parents) == 0 {
+				break
+			}
+			if _, ok := index[parents[0]]; !ok {
+				break
+			}
+			if _, ok := chainOf[parents[0]]; ok {
+				break
+			}
+			id = parents[0]
+		}
+		if name == "" {
+			name = fmt.Sprintf("lane-%d", n.column)
+		}
+		names = append(names, uniqueName(name, used))
+	}
+	return chainOf, names
+}
+
+// uniqueName name, or name-2, name-3, ... when already used
+func uniqueName(name string, used map[string]bool) string {
+	for unique, i := name, 2; ; i++ {
+		if !used[unique] {
+			used[unique] = true
+			return unique
+		}
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+}
+
+// Mermaid write a mermaid gitGraph of the nodes, parents first.
+// Every first-parent chain becomes a branch. Mermaid can only merge the head of a branch and does not have
+// orphan branches, so merges of older commits are written as commits and orphan branches start from the
+// current branch.
+func Mermaid(w io.Writer, nodes []map[string]interface{}, opts MermaidOptions) error {
+	rnodes, err := readNodes(nodes)
+	if err != nil {
+		return err
+	}
+	rnodes = withoutContext(rnodes)
+	index := make(map[string]node)
+	for _, n := range rnodes {
+		index[n.id] = n
+	}
+	inSet := func(id string) bool {
+		_, ok := index[id]
+		return ok
+	}
+	chainOf, names := chains(rnodes, index)
+
+	// Chains forking from a commit, created right after the commit so the branch starts from it
+	forks := make(map[string][]int)
+	first := make(map[int]string)
+	for i := len(rnodes) - 1; i >= 0; i-- {
+		n := rnodes[i]
+		if _, ok := first[chainOf[n.id]]; !ok {
+			first[chainOf[n.id]] = n.id
+			if len(n.parents) > 0 && inSet(n.parents[0]) {
+				forks[n.parents[0]] = append(forks[n.parents[0]], chainOf[n.id])
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	if opts.Markdown {
+		bw.WriteString("```mermaid\n")
+	}
+	current := -1
+	if len(rnodes) > 0 {
+		current = chainOf[rnodes[len(rnodes)-1].id]
+		if names[current] != mermaidDefaultBranch {
+			fmt.Fprintf(bw, "%%%%{init: {'gitGraph': {'mainBranchName': '%s'}}}%%%%\n", names[current])
+		}
+	}
+	bw.WriteString("gitGraph\n")
+	created := map[int]bool{current: true}
+	heads := make(map[int]string)
+	ids := make(map[string]bool)
+	checkout := func(chain int) {
+		if !created[chain] {
+			fmt.Fprintf(bw, "  branch %s\n", names[chain])
+			created[chain] = true
+		} else if chain != current {
+			fmt.Fprintf(bw, "  checkout %s\n", names[chain])
+		}
+		current = chain
+	}
+	for i := len(rnodes) - 1; i >= 0; i-- {
+		n := rnodes[i]
+		chain := chainOf[n.id]
+		checkout(chain)
+		// Short shas and ids without quotes may collide, mermaid needs unique ids
+		attrs := fmt.Sprintf("id: \"%s\"", uniqueName(strings.Replace(shortID(n.id), `"`, "", -1), ids))
+		for _, ref := range n.refs {
+			if ref.Type == git2graph.TagRef {
+				attrs += fmt.Sprintf(" tag: \"%s\"", strings.Replace(ref.Name, `"`, "", -1))
+				break
+			}
+		}
+		merged := -1
+		for j, parent := range n.parents {
+			if j > 0 && inSet(parent) && chainOf[parent] != chain && heads[chainOf[parent]] == parent {
+				merged = chainOf[parent]
+				break
+			}
+		}
+		if merged >= 0 {
+			fmt.Fprintf(bw, "  merge %s %s\n", names[merged], attrs)
+		} else {
+			fmt.Fprintf(bw, "  commit %s\n", attrs)
+		}
+		heads[chain] = n.id
+		for _, fork := range forks[n.id] {
+			checkout(chain)
+			checkout(fork)
+		}
+	}
+	if opts.Markdown {
+		bw.WriteString("```\n")
+	}
+	return bw.Flush()
+}
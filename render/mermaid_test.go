@@ -0,0 +1,137 @@
+package render
+
+import (
+	"bytes"
+	"git2graph/git2graph"
+	"testing"
+)
+
+func TestMermaid(t *testing.T) {
+	//	E feature, v1
+	//	| M master, merge of D
+	//	|/|
+	//	D |
+	//	| C
+	//	|/
+	//	B
+	//	A
+	nodes := buildTree(t, `[
+		{"id": "E", "parents": ["D"]},
+		{"id": "M", "parents": ["C", "D"]},
+		{"id": "D", "parents": ["B"]},
+		{"id": "C", "parents": ["B"]},
+		{"id": "B", "parents": ["A"]},
+		{"id": "A", "parents": []}
+	]`)
+	nodes[0]["refs"] = []git2graph.Ref{{Name: "feature", Type: git2graph.LocalBranchRef}, {Name: "v1", Type: git2graph.TagRef}}
+	nodes[1]["refs"] = []git2graph.Ref{{Name: "HEAD", Type: git2graph.HeadRef}, {Name: "master", Type: git2graph.LocalBranchRef}}
+
+	var buf bytes.Buffer
+	if err := Mermaid(&buf, nodes, MermaidOptions{Markdown: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "```mermaid\n" + `%%{init: {'gitGraph': {'mainBranchName': 'master'}}}%%
+gitGraph
+  commit id: "A"
+  commit id: "B"
+  branch feature
+  checkout master
+  commit id: "C"
+  checkout feature
+  commit id: "D"
+  checkout master
+  merge feature id: "M"
+  checkout feature
+  commit id: "E" tag: "v1"
+` + "```\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestMermaidSynthesizedBranches(t *testing.T) {
+	// No refs, the chains are named after their lanes. C is merged after D was committed on its branch.
+	nodes := buildTree(t, `[
+		{"id": "M", "parents": ["B", "C"]},
+		{"id": "D", "parents": ["C"]},
+		{"id": "C", "parents": ["A"]},
+		{"id": "B", "parents": ["A"]},
+		{"id": "A", "parents": []}
+	]`)
+	var buf bytes.Buffer
+	if err := Mermaid(&buf, nodes, MermaidOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `%%{init: {'gitGraph': {'mainBranchName': 'lane-0'}}}%%
+gitGraph
+  commit id: "A"
+  branch lane-2
+  checkout lane-0
+  commit id: "B"
+  checkout lane-2
+  commit id: "C"
+  commit id: "D"
+  checkout lane-0
+  commit id: "M"
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestMermaidIDs(t *testing.T) {
+	// Non-sha ids are kept whole, shas sharing their first 7 characters get unique ids
+	nodes := buildTree(t, `[
+		{"id": "commit-11", "parents": ["commit-10"]},
+		{"id": "commit-10", "parents": ["1234567aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"]},
+		{"id": "1234567aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "parents": ["1234567bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"]},
+		{"id": "1234567bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "parents": []}
+	]`)
+	var buf bytes.Buffer
+	if err := Mermaid(&buf, nodes, MermaidOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `%%{init: {'gitGraph': {'mainBranchName': 'lane-0'}}}%%
+gitGraph
+  commit id: "1234567"
+  commit id: "1234567-2"
+  commit id: "commit-10"
+  commit id: "commit-11"
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestMermaidBranchName(t *testing.T) {
+	inputs := map[string]string{
+		"feature/login": "feature/login",
+		"fix #12":       "fix-12",
+		"release.":      "release",
+		"":              "lane",
+	}
+	for name, expected := range inputs {
+		if actual := mermaidBranchName(name); actual != expected {
+			t.Errorf("Expected: %q, Actual: %q", expected, actual)
+		}
+	}
+}
+
+func TestMermaidJSONRefs(t *testing.T) {
+	nodes := buildTree(t, `[
+		{"id": "B", "parents": ["A"], "refs": [{"name": "HEAD", "type": "head"}, {"name": "trunk", "type": "branch"}]},
+		{"id": "A", "parents": []}
+	]`)
+	var buf bytes.Buffer
+	if err := Mermaid(&buf, nodes, MermaidOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := `%%{init: {'gitGraph': {'mainBranchName': 'trunk'}}}%%
+gitGraph
+  commit id: "A"
+  commit id: "B"
+`
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
@@ -0,0 +1,149 @@
+package render
+
+import (
+	"git2graph/git2graph"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+)
+
+// PNGOptions options of the png renderer
+type PNGOptions struct {
+	Geometry
+	Background color.Color // nil for a transparent background
+}
+
+// DefaultPNGOptions same sizes as tools/renderer, on a white background
+var DefaultPNGOptions = PNGOptions{Geometry: DefaultGeometry, Background: color.White}
+
+const (
+	pngLineWidth   = 2
+	pngStrokeWidth = 1.0 // Width of the black outline of the nodes
+)
+
+// mask anti-aliased coverage of a shape, 0 outside and 255 inside
+type mask struct {
+	*image.Alpha
+}
+
+func newMask(r image.Rectangle) mask {
+	return mask{image.NewAlpha(r)}
+}
+
+// cover keep the biggest coverage of the pixel, so overlapping segments of a path are not blended twice
+func (m mask) cover(x, y int, coverage float64) {
+	if !(image.Point{x, y}.In(m.Rect)) || coverage <= 0 {
+		return
+	}
+	a := uint8(math.Min(coverage, 1) * 255)
+	if i := m.PixOffset(x, y); a > m.Pix[i] {
+		m.Pix[i] = a
+	}
+}
+
+// segment cover the pixels within width/2 of the segment [(x1, y1), (x2, y2)]
+func (m mask) segment(x1, y1, x2, y2, width float64) {
+	half := width / 2
+	r := image.Rect(int(math.Floor(math.Min(x1, x2)-half-1)), int(math.Floor(math.Min(y1, y2)-half-1)),
+		int(math.Ceil(math.Max(x1, x2)+half+1)), int(math.Ceil(math.Max(y1, y2)+half+1))).Intersect(m.Rect)
+	dx, dy := x2-x1, y2-y1
+	length2 := dx*dx + dy*dy
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			// Distance between the center of the pixel and the segment
+			px, py := float64(x)+0.5, float64(y)+0.5
+			t := 0.0
+			if length2 > 0 {
+				t = math.Max(0, math.Min(1, ((px-x1)*dx+(py-y1)*dy)/length2))
+			}
+			d := math.Hypot(px-(x1+t*dx), py-(y1+t*dy))
+			m.cover(x, y, half+0.5-d)
+		}
+	}
+}
+
+// disc cover the pixels whose distance to the center is between inner and outer
+func (m mask) disc(cx, cy, inner, outer float64) {
+	r := image.Rect(int(cx-outer-1), int(cy-outer-1), int(cx+outer+2), int(cy+outer+2)).Intersect(m.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy)
+			m.cover(x, y, math.Min(outer+0.5-d, d-inner+0.5))
+		}
+	}
+}
+
+func parseColor(c string) color.Color {
+	r, g, b, ok := rgb(colorOr(c))
+	if !ok {
+		r, g, b, _ = rgb(defaultColor)
+	}
+	return color.RGBA{r, g, b, 255}
+}
+
+// Image draw the graph, without labels
+func Image(nodes []map[string]interface{}, opts PNGOptions) (*image.RGBA, error) {
+	rnodes, err := readNodes(nodes)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Geometry == (Geometry{}) {
+		opts.Geometry = DefaultGeometry
+	}
+	minIdx, maxIdx, maxCol := bounds(rnodes)
+	if len(rnodes) == 0 {
+		minIdx, maxIdx = 0, -1
+	}
+	width, height := opts.size(maxIdx-minIdx+1, maxCol)
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	if opts.Background != nil {
+		draw.Draw(img, img.Bounds(), image.NewUniform(opts.Background), image.Point{}, draw.Src)
+	}
+
+	fill := func(m mask, c color.Color) {
+		draw.DrawMask(img, m.Rect, image.NewUniform(c), image.Point{}, m, m.Rect.Min, draw.Over)
+	}
+	for _, n := range rnodes {
+		for _, path := range n.paths {
+			var r image.Rectangle
+			for _, p := range path.Path {
+				x, y := opts.point(p, minIdx)
+				r = r.Union(image.Rect(int(x)-pngLineWidth, int(y)-pngLineWidth, int(x)+pngLineWidth+1, int(y)+pngLineWidth+1))
+			}
+			m := newMask(r.Intersect(img.Bounds()))
+			for i := 0; i+1 < len(path.Path); i++ {
+				x1, y1 := opts.point(path.Path[i], minIdx)
+				x2, y2 := opts.point(path.Path[i+1], minIdx)
+				m.segment(x1, y1, x2, y2, pngLineWidth)
+			}
+			fill(m, parseColor(path.Color))
+		}
+	}
+	for _, n := range rnodes {
+		if n.context {
+			continue
+		}
+		cx, cy := opts.point(git2graph.Point{X: n.column, Y: n.idx}, minIdx)
+		radius := float64(opts.Radius)
+		r := image.Rect(int(cx)-opts.Radius-2, int(cy)-opts.Radius-2, int(cx)+opts.Radius+3, int(cy)+opts.Radius+3).Intersect(img.Bounds())
+		m := newMask(r)
+		m.disc(cx, cy, 0, radius)
+		fill(m, parseColor(n.color))
+		m = newMask(r)
+		m.disc(cx, cy, radius-pngStrokeWidth/2, radius+pngStrokeWidth/2)
+		fill(m, color.Black)
+	}
+	return img, nil
+}
+
+// PNG write the graph as a png image
+func PNG(w io.Writer, nodes []map[string]interface{}, opts PNGOptions) error {
+	img, err := Image(nodes, opts)
+	if err != nil {
+		return err
+	}
+	return png.Encode(w, img)
+}
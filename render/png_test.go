@@ -0,0 +1,70 @@
+package render
+
+import (
+	"bytes"
+	"git2graph/git2graph"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestPNG(t *testing.T) {
+	nodes := buildTree(t, `[
+		{"id": "1", "parents": ["3", "2"]},
+		{"id": "2", "parents": ["3"]},
+		{"id": "3", "parents": []}
+	]`)
+	var buf bytes.Buffer
+	if err := PNG(&buf, nodes, DefaultPNGOptions); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != (image.Point{21, 50}) {
+		t.Errorf("Expected: 21x50, Actual: %v", size)
+	}
+	rgba := func(x, y int) color.RGBA {
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+	// Center of the second node, at column 1 and row 1
+	if c, expected := rgba(16, 25), parseColor(nodes[1]["color"].(string)); c != expected {
+		t.Errorf("Expected: %v, Actual: %v", expected, c)
+	}
+	if c := rgba(0, 49); c != (color.RGBA{255, 255, 255, 255}) {
+		t.Errorf("Expected a white background, Actual: %v", c)
+	}
+	// The first lane is 2 pixels wide and centered on x=5
+	if c := rgba(4, 30); c != parseColor(nodes[0]["parents_paths"].([]git2graph.Path)[0].Color) {
+		t.Errorf("Expected the lane color, Actual: %v", c)
+	}
+	// The fork from (5, 5) to (16, 13) is anti-aliased
+	forkColor := parseColor(nodes[0]["parents_paths"].([]git2graph.Path)[1].Color)
+	antiAliased := false
+	for x := 7; x < 14; x++ {
+		for y := 6; y < 13; y++ {
+			if c := rgba(x, y); c != forkColor && c != (color.RGBA{255, 255, 255, 255}) {
+				antiAliased = true
+			}
+		}
+	}
+	if !antiAliased {
+		t.Error("Expected anti-aliased edges")
+	}
+}
+
+func TestImageGeometry(t *testing.T) {
+	nodes := buildTree(t, `[{"id": "1", "parents": ["2"]}, {"id": "2", "parents": []}]`)
+	img, err := Image(nodes, PNGOptions{Geometry: Geometry{ColumnWidth: 30, RowHeight: 40, Radius: 10}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if size := img.Bounds().Size(); size != (image.Point{22, 62}) {
+		t.Errorf("Expected: 22x62, Actual: %v", size)
+	}
+	if c := img.RGBAAt(0, 0); c.A != 0 {
+		t.Errorf("Expected a transparent background, Actual: %v", c)
+	}
+}
@@ -0,0 +1,201 @@
+// Package render draws the graph computed by git2graph.BuildTree
+package render
+
+import (
+	"fmt"
+	"git2graph/git2graph"
+	"regexp"
+)
+
+// Geometry sizes of the graph drawn by the image renderers, in pixels
+type Geometry struct {
+	ColumnWidth int // Horizontal distance between two lanes
+	RowHeight   int // Vertical distance between two nodes
+	Radius      int // Radius of the nodes
+}
+
+// DefaultGeometry same sizes as tools/renderer
+var DefaultGeometry = Geometry{ColumnWidth: 11, RowHeight: 20, Radius: 4}
+
+// defaultColor color of the nodes and paths without color
+const defaultColor = "#5aa1be"
+
+// margin space around the graph, enough for the nodes of the first and last lanes and rows
+func (g Geometry) margin() int {
+	return g.Radius + 1
+}
+
+// size width and height of the graph
+func (g Geometry) size(rows, maxCol int) (width, height int) {
+	if rows < 1 {
+		rows = 1
+	}
+	return 2*g.margin() + maxCol*g.ColumnWidth, 2*g.margin() + (rows-1)*g.RowHeight
+}
+
+// point position of a path point relative to the first row, corners are moved toward the row of their node
+func (g Geometry) point(p git2graph.Point, minIdx int) (x, y float64) {
+	x = float64(g.margin() + p.X*g.ColumnWidth)
+	y = float64(g.margin() + (p.Y-minIdx)*g.RowHeight)
+	gap := 2 / 5.0 * float64(g.RowHeight)
+	switch p.Type {
+	case git2graph.MERGE_BACK:
+		y -= gap
+	case git2graph.FORK, git2graph.MERGE_TO:
+		y += gap
+	}
+	return x, y
+}
+
+// node fields used by the renderers
+type node struct {
+	id      string
+	parents []string
+	idx     int
+	column  int
+	color   string
+	subject string
+	paths   []git2graph.Path
+	refs    []git2graph.Ref
+	context bool // Node before a page, only its paths crossing the page are drawn (git2graph.Page.Rows)
+}
+
+// readNodes read the properties of the nodes returned by git2graph.Get or git2graph.BuildTree
+func readNodes(nodes []map[string]interface{}) ([]node, error) {
+	out := make([]node, 0, len(nodes))
+	for i, n := range nodes {
+		rn := node{}
+		var ok bool
+		if rn.id, ok = n["id"].(string); !ok {
+			return nil, fmt.Errorf("node %d: id property must be a string", i)
+		}
+		if rn.idx, ok = n["idx"].(int); !ok {
+			return nil, fmt.Errorf("node %d: idx property must be an int", i)
+		}
+		if rn.column, ok = n["column"].(int); !ok {
+			return nil, fmt.Errorf("node %d: column property must be an int", i)
+		}
+		if rn.paths, ok = n["parents_paths"].([]git2graph.Path); !ok {
+			return nil, fmt.Errorf("node %d: parents_paths property must be a []git2graph.Path", i)
+		}
+		rn.parents, _ = n["parents"].([]string)
+		rn.color, _ = n["color"].(string)
+		rn.subject, _ = n["subject"].(string)
+		rn.refs = git2graph.NodeRefs(n)
+		rn.context, _ = n["context"].(bool)
+		out = append(out, rn)
+	}
+	return out, nil
+}
+
+// withoutContext nodes of the page, for the renderers that do not draw paths on their own
+func withoutContext(nodes []node) []node {
+	out := make([]node, 0, len(nodes))
+	for _, n := range nodes {
+		if !n.context {
+			out = append(out, n)
+		}
+	}
+	return out
+}
+
+// bounds first and last rows, and last column used by the nodes and their paths (only the paths of context nodes)
+func bounds(nodes []node) (minIdx, maxIdx, maxCol int) {
+	minIdx, maxIdx = -1, -1
+	for _, n := range nodes {
+		for _, path := range n.paths {
+			for _, point := range path.Path {
+				if point.X > maxCol {
+					maxCol = point.X
+				}
+			}
+		}
+		if n.context {
+			continue
+		}
+		if minIdx == -1 || n.idx < minIdx {
+			minIdx = n.idx
+		}
+		if n.idx > maxIdx {
+			maxIdx = n.idx
+		}
+		if n.column > maxCol {
+			maxCol = n.column
+		}
+	}
+	return
+}
+
+// legendLabel eg: "alain@example.com (12)"
+func legendLabel(entry git2graph.LegendEntry) string {
+	value := entry.Value
+	if value == "" {
+		value = "(none)"
+	}
+	return fmt.Sprintf("%s (%d)", value, entry.Count)
+}
+
+var shaRegexp = regexp.MustCompile("^[0-9a-f]{40}$")
+
+// shortID first 7 characters of a sha, other ids are kept whole
+func shortID(id string) string {
+	if shaRegexp.MatchString(id) {
+		return id[:7]
+	}
+	return id
+}
+
+// refsLabel eg: "HEAD -> master, tag: v1.0, origin/master"
+func refsLabel(refs []git2graph.Ref) string {
+	label := ""
+	for i, ref := range refs {
+		switch {
+		case ref.Type == git2graph.HeadRef && i+1 < len(refs) && refs[i+1].Type == git2graph.LocalBranchRef:
+			label += "HEAD -> "
+			continue
+		case ref.Type == git2graph.TagRef:
+			label += "tag: "
+		}
+		label += ref.Name
+		if i+1 < len(refs) {
+			label += ", "
+		}
+	}
+	return label
+}
+
+// label refs and subject of a node, eg: "(HEAD -> master) Fix typo"
+func label(n node) string {
+	l := ""
+	if len(n.refs) > 0 {
+		l = "(" + refsLabel(n.refs) + ")"
+	}
+	if n.subject != "" {
+		if l != "" {
+			l += " "
+		}
+		l += n.subject
+	}
+	return l
+}
+
+// colorOr color, or the default color when empty
+func colorOr(color string) string {
+	if color == "" {
+		return defaultColor
+	}
+	return color
+}
+
+// rgb parse #rgb and #rrggbb colors
+func rgb(color string) (r, g, b uint8, ok bool) {
+	var n int
+	switch len(color) {
+	case 4:
+		n, _ = fmt.Sscanf(color, "#%1x%1x%1x", &r, &g, &b)
+		r, g, b = r*17, g*17, b*17
+	case 7:
+		n, _ = fmt.Sscanf(color, "#%2x%2x%2x", &r, &g, &b)
+	}
+	return r, g, b, n == 3
+}
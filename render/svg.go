@@ -0,0 +1,122 @@
+package render
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"git2graph/git2graph"
+	"io"
+	"math"
+	"strings"
+)
+
+// SVGOptions options of the svg renderer
+type SVGOptions struct {
+	Geometry
+	Labels bool                    // Write the short id on the left of the graph, the refs and subject on its right
+	Legend []git2graph.LegendEntry // Drawn below the graph, see git2graph.ColorBy
+}
+
+// DefaultSVGOptions same look as tools/renderer
+var DefaultSVGOptions = SVGOptions{Geometry: DefaultGeometry, Labels: true}
+
+const (
+	svgFontSize  = 12
+	svgCharWidth = 7.2 // Approximative width of a monospace character at svgFontSize
+	svgShaMargin = 60  // Room for the short id on the left of the graph
+)
+
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// SVG write a self-contained svg document of the graph
+func SVG(w io.Writer, nodes []map[string]interface{}, opts SVGOptions) error {
+	rnodes, err := readNodes(nodes)
+	if err != nil {
+		return err
+	}
+	if opts.Geometry == (Geometry{}) {
+		opts.Geometry = DefaultGeometry
+	}
+	minIdx, maxIdx, maxCol := bounds(rnodes)
+	if len(rnodes) == 0 {
+		minIdx, maxIdx = 0, -1
+	}
+	graphX := 0
+	if opts.Labels {
+		graphX = svgShaMargin
+	}
+	graphWidth, graphHeight := opts.size(maxIdx-minIdx+1, maxCol)
+	labelX := graphX + graphWidth + 5
+	width := float64(graphX + graphWidth)
+	height := graphHeight + len(opts.Legend)*opts.RowHeight
+	legendX := graphX + 2*opts.margin() + 5
+	for _, entry := range opts.Legend {
+		if legendWidth := float64(legendX) + float64(len([]rune(legendLabel(entry))))*svgCharWidth; legendWidth > width {
+			width = legendWidth
+		}
+	}
+	if opts.Labels {
+		for _, n := range rnodes {
+			if n.context {
+				continue
+			}
+			if labelWidth := float64(labelX) + float64(len([]rune(label(n))))*svgCharWidth; labelWidth > width {
+				width = labelWidth
+			}
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%.0f\" height=\"%d\" viewBox=\"0 0 %.0f %d\">\n", math.Ceil(width), height, math.Ceil(width), height)
+	fmt.Fprintf(bw, "<g transform=\"translate(%d,0)\" fill=\"none\" stroke-width=\"2\">\n", graphX)
+	for _, n := range rnodes {
+		for _, path := range n.paths {
+			points := make([]string, 0, len(path.Path))
+			for _, p := range path.Path {
+				x, y := opts.point(p, minIdx)
+				points = append(points, fmt.Sprintf("%g,%g", x, y))
+			}
+			fmt.Fprintf(bw, "<polyline points=\"%s\" stroke=\"%s\"/>\n", strings.Join(points, " "), escape(colorOr(path.Color)))
+		}
+	}
+	bw.WriteString("</g>\n")
+	fmt.Fprintf(bw, "<g transform=\"translate(%d,0)\" stroke=\"black\">\n", graphX)
+	for _, n := range rnodes {
+		if n.context {
+			continue
+		}
+		x, y := opts.point(git2graph.Point{X: n.column, Y: n.idx}, minIdx)
+		fmt.Fprintf(bw, "<circle cx=\"%g\" cy=\"%g\" r=\"%d\" fill=\"%s\"><title>%s</title></circle>\n",
+			x, y, opts.Radius, escape(colorOr(n.color)), escape(n.id))
+	}
+	bw.WriteString("</g>\n")
+	if opts.Labels {
+		fmt.Fprintf(bw, "<g font-size=\"%d\" font-family=\"Consolas, 'Liberation Mono', Menlo, Courier, monospace\" dominant-baseline=\"middle\">\n", svgFontSize)
+		for _, n := range rnodes {
+			if n.context {
+				continue
+			}
+			_, y := opts.point(git2graph.Point{X: n.column, Y: n.idx}, minIdx)
+			fmt.Fprintf(bw, "<text x=\"0\" y=\"%g\">%s</text>\n", y, escape(shortID(n.id)))
+			if l := label(n); l != "" {
+				fmt.Fprintf(bw, "<text x=\"%d\" y=\"%g\">%s</text>\n", labelX, y, escape(l))
+			}
+		}
+		bw.WriteString("</g>\n")
+	}
+	if len(opts.Legend) > 0 {
+		fmt.Fprintf(bw, "<g font-size=\"%d\" font-family=\"Consolas, 'Liberation Mono', Menlo, Courier, monospace\" dominant-baseline=\"middle\">\n", svgFontSize)
+		for i, entry := range opts.Legend {
+			y := graphHeight + i*opts.RowHeight + opts.RowHeight/2
+			fmt.Fprintf(bw, "<circle cx=\"%d\" cy=\"%d\" r=\"%d\" fill=\"%s\" stroke=\"black\"/>\n", graphX+opts.margin(), y, opts.Radius, escape(colorOr(entry.Color)))
+			fmt.Fprintf(bw, "<text x=\"%d\" y=\"%d\">%s</text>\n", legendX, y, escape(legendLabel(entry)))
+		}
+		bw.WriteString("</g>\n")
+	}
+	bw.WriteString("</svg>\n")
+	return bw.Flush()
+}
@@ -0,0 +1,54 @@
+package render
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestSVG(t *testing.T) {
+	nodes := buildTree(t, `[
+		{"id": "1", "parents": ["3", "2"], "subject": "Merge <feature>"},
+		{"id": "2", "parents": ["3"]},
+		{"id": "3", "parents": []}
+	]`)
+	var buf bytes.Buffer
+	if err := SVG(&buf, nodes, DefaultSVGOptions); err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Polylines []struct {
+			Points string `xml:"points,attr"`
+		} `xml:"g>polyline"`
+		Circles []struct{} `xml:"g>circle"`
+		Texts   []string   `xml:"g>text"`
+	}
+	if err := xml.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("Invalid svg: %v\n%s", err, buf.String())
+	}
+	if len(doc.Circles) != 3 {
+		t.Errorf("Expected 3 circles, Actual: %d", len(doc.Circles))
+	}
+	// The fork starts below the merge node, the merge back ends above the last node
+	expected := []string{"5,5 5,45", "5,5 16,13 16,25", "16,25 16,37 5,45"}
+	if len(doc.Polylines) != len(expected) {
+		t.Fatalf("Expected %d polylines, Actual: %d", len(expected), len(doc.Polylines))
+	}
+	for i, polyline := range doc.Polylines {
+		if polyline.Points != expected[i] {
+			t.Errorf("Expected: %s, Actual: %s", expected[i], polyline.Points)
+		}
+	}
+	if len(doc.Texts) != 4 || doc.Texts[1] != "Merge <feature>" {
+		t.Errorf("Expected the ids and the subject, Actual: %v", doc.Texts)
+	}
+
+	buf.Reset()
+	if err := SVG(&buf, nodes, SVGOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "<text") {
+		t.Error("Expected no labels")
+	}
+}
@@ -0,0 +1,204 @@
+package render
+
+import (
+	"bufio"
+	"fmt"
+	"git2graph/git2graph"
+	"io"
+	"strings"
+)
+
+// TextOptions options of the text renderer
+type TextOptions struct {
+	ASCII  bool                    // Use ASCII characters instead of box-drawing characters
+	Color  bool                    // Color the lanes with ANSI escape codes
+	Legend []git2graph.LegendEntry // Written after the graph, see git2graph.ColorBy
+}
+
+// Cell connections
+const (
+	up = 1 << iota
+	down
+	left
+	right
+)
+
+var unicodeChars = map[uint8]string{
+	up: "│", down: "│", up | down: "│",
+	left: "─", right: "─", left | right: "─",
+	up | left: "┘", up | right: "└", down | left: "┐", down | right: "┌",
+	up | down | left: "┤", up | down | right: "├", up | left | right: "┴", down | left | right: "┬",
+	up | down | left | right: "┼",
+}
+
+var asciiChars = map[uint8]string{
+	up: "|", down: "|", up | down: "|",
+	left: "-", right: "-", left | right: "-",
+	up | left: "/", up | right: "\\", down | left: "\\", down | right: "/",
+	up | down | left: "+", up | down | right: "+", up | left | right: "+", down | left | right: "+",
+	up | down | left | right: "+",
+}
+
+type cell struct {
+	links      uint8
+	color      string
+	rightColor string // Color of the horizontal line between this cell and the next one
+	node       bool
+}
+
+// grid one row per node, one cell per column
+type grid struct {
+	minIdx int
+	cells  [][]cell
+}
+
+func newGrid(nodes []node) *grid {
+	g := &grid{}
+	minIdx, maxIdx, maxCol := bounds(nodes)
+	g.minIdx = minIdx
+	g.cells = make([][]cell, maxIdx-minIdx+1)
+	for i := range g.cells {
+		g.cells[i] = make([]cell, maxCol+1)
+	}
+	for _, n := range nodes {
+		for _, path := range n.paths {
+			g.drawPath(path.Path, path.Color)
+		}
+	}
+	for _, n := range nodes {
+		if c := g.cell(n.idx, n.column); c != nil {
+			c.node = true
+			c.color = n.color
+		}
+	}
+	return g
+}
+
+// cell get a cell, nil when outside of the grid (paths of a page can go past its rows)
+func (g *grid) cell(idx, column int) *cell {
+	row := idx - g.minIdx
+	if row < 0 || row >= len(g.cells) || column < 0 || column >= len(g.cells[row]) {
+		return nil
+	}
+	return &g.cells[row][column]
+}
+
+func (g *grid) link(idx, column int, links uint8, color string) {
+	if c := g.cell(idx, column); c != nil {
+		c.links |= links
+		c.color = color
+		if links&right != 0 {
+			c.rightColor = color
+		}
+	}
+}
+
+func (g *grid) vertical(column, fromIdx, toIdx int, color string) {
+	if fromIdx > toIdx {
+		fromIdx, toIdx = toIdx, fromIdx
+	}
+	if fromIdx == toIdx {
+		return
+	}
+	g.link(fromIdx, column, down, color)
+	for idx := fromIdx + 1; idx < toIdx; idx++ {
+		g.link(idx, column, up|down, color)
+	}
+	g.link(toIdx, column, up, color)
+}
+
+func (g *grid) horizontal(idx, fromColumn, toColumn int, color string) {
+	if fromColumn > toColumn {
+		fromColumn, toColumn = toColumn, fromColumn
+	}
+	if fromColumn == toColumn {
+		return
+	}
+	g.link(idx, fromColumn, right, color)
+	for column := fromColumn + 1; column < toColumn; column++ {
+		g.link(idx, column, left|right, color)
+	}
+	g.link(idx, toColumn, left, color)
+}
+
+// drawPath draw the segments between the points of a path.
+// MERGE_BACK, FORK and MERGE_TO points are corners, the lane turns on the row of the point.
+func (g *grid) drawPath(points []git2graph.Point, color string) {
+	for i := 0; i+1 < len(points); i++ {
+		p, q := points[i], points[i+1]
+		switch {
+		case p.Y == q.Y:
+			g.horizontal(p.Y, p.X, q.X, color)
+		case p.X == q.X:
+			g.vertical(p.X, p.Y, q.Y, color)
+		default:
+			g.vertical(p.X, p.Y, q.Y, color)
+			g.horizontal(q.Y, p.X, q.X, color)
+		}
+	}
+}
+
+// Text write the graph with one line per node, followed by the node id, refs and subject
+func Text(w io.Writer, nodes []map[string]interface{}, opts TextOptions) error {
+	rnodes, err := readNodes(nodes)
+	if err != nil {
+		return err
+	}
+	if len(rnodes) == 0 {
+		return nil
+	}
+	g := newGrid(rnodes)
+	byIdx := make(map[int]node)
+	for _, n := range rnodes {
+		byIdx[n.idx] = n
+	}
+	chars, nodeChar := unicodeChars, "●"
+	if opts.ASCII {
+		chars, nodeChar = asciiChars, "*"
+	}
+	paint := func(s, color string) string {
+		if !opts.Color || s == " " {
+			return s
+		}
+		r, gr, b, ok := rgb(color)
+		if !ok {
+			return s
+		}
+		return fmt.Sprintf("\x1b[38;2;%d;%d;%dm%s\x1b[0m", r, gr, b, s)
+	}
+
+	bw := bufio.NewWriter(w)
+	for row, cells := range g.cells {
+		var line strings.Builder
+		for column, c := range cells {
+			switch {
+			case c.node:
+				line.WriteString(paint(nodeChar, c.color))
+			case c.links != 0:
+				line.WriteString(paint(chars[c.links], c.color))
+			default:
+				line.WriteString(" ")
+			}
+			if column+1 < len(cells) {
+				if c.links&right != 0 {
+					line.WriteString(paint(chars[left|right], c.rightColor))
+				} else {
+					line.WriteString(" ")
+				}
+			}
+		}
+		if n, ok := byIdx[row+g.minIdx]; ok {
+			line.WriteString("  " + shortID(n.id) + " " + label(n))
+		}
+		if _, err := bw.WriteString(strings.TrimRight(line.String(), " ") + "\n"); err != nil {
+			return err
+		}
+	}
+	if len(opts.Legend) > 0 {
+		bw.WriteString("\n")
+	}
+	for _, entry := range opts.Legend {
+		fmt.Fprintf(bw, "%s %s\n", paint(nodeChar, entry.Color), legendLabel(entry))
+	}
+	return bw.Flush()
+}
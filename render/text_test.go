@@ -0,0 +1,126 @@
+package render
+
+import (
+	"bytes"
+	"git2graph/git2graph"
+	"testing"
+)
+
+func buildTree(t *testing.T, json string) []map[string]interface{} {
+	nodes, err := git2graph.GetInputNodesFromJSON([]byte(json))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := git2graph.BuildTree(nodes, git2graph.DefaultColors)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestText(t *testing.T) {
+	nodes := buildTree(t, `[
+		{"id": "1", "parents": ["3", "2"], "subject": "Merge", "refs": []},
+		{"id": "2", "parents": ["3"]},
+		{"id": "3", "parents": []}
+	]`)
+	nodes[0]["refs"] = []git2graph.Ref{{Name: "HEAD", Type: git2graph.HeadRef}, {Name: "master", Type: git2graph.LocalBranchRef}}
+
+	inputs := []struct {
+		opts     TextOptions
+		expected string
+	}{
+		{TextOptions{}, "●─┐  1 (HEAD -> master) Merge\n│ ●  2\n●─┘  3\n"},
+		{TextOptions{ASCII: true}, "*-\\  1 (HEAD -> master) Merge\n| *  2\n*-/  3\n"},
+	}
+	for _, input := range inputs {
+		var buf bytes.Buffer
+		if err := Text(&buf, nodes, input.opts); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != input.expected {
+			t.Errorf("Expected:\n%s\nActual:\n%s", input.expected, buf.String())
+		}
+	}
+}
+
+func TestTextJSONRefs(t *testing.T) {
+	// Refs decoded from json are maps, not []git2graph.Ref
+	nodes := buildTree(t, `[
+		{"id": "1", "parents": ["2"], "refs": [{"name": "HEAD", "type": "head"}, {"name": "master", "type": "branch"}]},
+		{"id": "2", "parents": [], "refs": [{"name": "v1", "type": "tag"}]}
+	]`)
+	var buf bytes.Buffer
+	if err := Text(&buf, nodes, TextOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "●  1 (HEAD -> master)\n●  2 (tag: v1)\n"
+	if buf.String() != expected {
+		t.Errorf("Expected:\n%s\nActual:\n%s", expected, buf.String())
+	}
+}
+
+func TestTextColor(t *testing.T) {
+	nodes := buildTree(t, `[{"id": "1", "parents": []}]`)
+	nodes[0]["color"] = "#f00"
+	var buf bytes.Buffer
+	if err := Text(&buf, nodes, TextOptions{Color: true}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "\x1b[38;2;255;0;0m●\x1b[0m  1\n"
+	if buf.String() != expected {
+		t.Errorf("Expected: %q, Actual: %q", expected, buf.String())
+	}
+}
+
+func TestTextLegend(t *testing.T) {
+	nodes := buildTree(t, `[{"id": "1", "parents": []}]`)
+	legend := []git2graph.LegendEntry{{Value: "alain@example.com", Color: "#f00", Count: 1}, {Value: "", Color: "#0f0", Count: 2}}
+	var buf bytes.Buffer
+	if err := Text(&buf, nodes, TextOptions{ASCII: true, Legend: legend}); err != nil {
+		t.Fatal(err)
+	}
+	expected := "*  1\n\n* alain@example.com (1)\n* (none) (2)\n"
+	if buf.String() != expected {
+		t.Errorf("Expected: %q, Actual: %q", expected, buf.String())
+	}
+}
+
+func TestTextContext(t *testing.T) {
+	nodes := buildTree(t, `[
+		{"id": "1", "parents": ["3", "2"]},
+		{"id": "2", "parents": ["3"]},
+		{"id": "3", "parents": []}
+	]`)
+	for _, node := range nodes {
+		delete(node, "parentsPaths")
+	}
+	page, err := git2graph.Window(nodes, 1, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The lane from 1 to 3 goes through the window, it is only drawn with the context nodes
+	inputs := []struct {
+		nodes    []map[string]interface{}
+		expected string
+	}{
+		{page.Nodes, "  ●  2\n"},
+		{page.Rows(), "│ ●  2\n"},
+	}
+	for _, input := range inputs {
+		var buf bytes.Buffer
+		if err := Text(&buf, input.nodes, TextOptions{}); err != nil {
+			t.Fatal(err)
+		}
+		if buf.String() != input.expected {
+			t.Errorf("Expected:\n%s\nActual:\n%s", input.expected, buf.String())
+		}
+	}
+}
+
+func TestTextMalformedNodes(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Text(&buf, []map[string]interface{}{{"id": "1"}}, TextOptions{}); err == nil {
+		t.Error("Expected an error for a node without layout properties")
+	}
+}